@@ -0,0 +1,226 @@
+// Package api serves the commands of a command tree as an HTTP/JSON API, so that programs can run the same
+// operations as the command line without a second implementation.
+//
+// A leaf command, one with a handler and no subcommands, is run by POST /commands/<path>, such as /commands/app/db/migrate,
+// with a JSON body of option values and args:
+//
+//	{"options": {"port": 8080, "verbose": true, "hosts": ["a", "b"]}, "args": ["x", "y"]}
+//
+// The body is turned into a command line and run through the same parsing and validation as the command line,
+// then the handler runs with its lifecycle hooks, with its output captured. The response is
+//
+//	{"stdout": "...", "stderr": "...", "exit_code": 0, "error": ""}
+//
+// with status 200 if the command succeeded, 400 if the request did not fit the command, and 500 if the command failed.
+//
+// As on the command line, an option cannot be given an empty string or an empty list, and the items of a list
+// cannot contain commas, since a list is passed as a comma-separated --option=a,b. Such requests get status 400.
+// So do requests that set the configuration file option of the Runner, which would let a caller make the server read any file.
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/SpencerBrown/go-http/command"
+	"github.com/SpencerBrown/go-http/option"
+	"github.com/SpencerBrown/go-http/run"
+)
+
+// Prefix is the start of the URL path of every command
+const Prefix = "/commands/"
+
+// maxBodySize is the largest request body accepted
+const maxBodySize = 1 << 20
+
+// Request is the JSON body of a request to run a command
+type Request struct {
+	Options map[string]any `json:"options"` // option values by name or alias: strings, numbers, booleans or lists of them
+	Args    []any          `json:"args"`    // the command line arguments: strings, numbers or booleans
+}
+
+// Response is the JSON body of the response
+type Response struct {
+	Stdout   string `json:"stdout"`    // what the command wrote to its output
+	Stderr   string `json:"stderr"`    // what the command wrote to its error output
+	ExitCode int    `json:"exit_code"` // the exit code the command would have on the command line, see run.ExitCode
+	Error    string `json:"error"`     // the error message, or "" if the command succeeded
+}
+
+// Handler is an http.Handler that runs commands from a template Runner.
+type Handler struct {
+	runner *run.Runner // template for the Runner of each request
+}
+
+// NewHandler creates a Handler for the commands of r. Each request runs with a new Runner with its own args and streams,
+// and the commands, environment, configuration and working directory settings of r.
+func NewHandler(r *run.Runner) *Handler {
+	return &Handler{runner: r}
+}
+
+// ServeHTTP runs the command named by the URL path with the options and args in the JSON body
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path, ok := strings.CutPrefix(req.URL.Path, Prefix)
+	if !ok || h.runner.Commands == nil {
+		http.NotFound(w, req)
+		return
+	}
+	names, opts, err := leafPath(*h.runner.Commands, strings.Split(strings.Trim(path, "/"), "/"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	var body Request
+	dec := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBodySize))
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		http.Error(w, "api: invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if cfgOpt := option.GetOptionByName(opts, h.runner.ConfigOption); h.runner.ConfigOption != "" && cfgOpt != nil {
+		// the configuration file is the server's, a request must not make it read another file
+		for key := range body.Options {
+			if option.GetOptionByName(opts, key) == cfgOpt {
+				http.Error(w, fmt.Sprintf("api: option %s cannot be set by a request", key), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	args, err := commandLine(names, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var stdout, stderr bytes.Buffer
+	progName := ""
+	if len(h.runner.Args) > 0 {
+		progName = h.runner.Args[0]
+	}
+	r := &run.Runner{
+		Commands:     h.runner.Commands,
+		Args:         append([]string{progName}, args...),
+		GetEnvVar:    h.runner.GetEnvVar,
+		LookupEnvVar: h.runner.LookupEnvVar,
+		EnvPrefix:    h.runner.EnvPrefix,
+		ConfigOption: h.runner.ConfigOption,
+		Strict:       h.runner.Strict,
+		GetWorkDir:   h.runner.GetWorkDir,
+		Input:        strings.NewReader(""),
+		Output:       &stdout,
+		ErrorOutput:  &stderr,
+	}
+	err = r.Execute(req.Context())
+
+	resp := Response{ExitCode: run.ExitCode(err)}
+	status := http.StatusOK
+	if err != nil {
+		resp.Error = err.Error()
+		status = http.StatusInternalServerError
+		var usageErr *run.UsageError
+		if errors.As(err, &usageErr) {
+			status = http.StatusBadRequest
+		}
+	}
+	resp.Stdout = stdout.String()
+	resp.Stderr = stderr.String()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// leafPath finds the command at the path of names or aliases, which must be a leaf with a handler,
+// and returns the names of the commands on the path and the options that can be given for the command
+func leafPath(cmds command.Commands, path []string) ([]string, option.Options, error) {
+	names := make([]string, 0, len(path))
+	opts := option.NewOptions()
+	var cmd *command.Command
+	for _, name := range path {
+		cmd = command.GetCommandByName(cmds, name)
+		if cmd == nil {
+			return nil, nil, fmt.Errorf("api: unknown command %s", strings.Join(append(names, name), " "))
+		}
+		names = append(names, cmd.Name())
+		opts = append(opts, cmd.PersistentOptions()...)
+		cmds = cmd.Subcommands()
+	}
+	if cmd == nil || len(cmd.Subcommands()) > 0 || cmd.Handler() == nil {
+		return nil, nil, fmt.Errorf("api: %s is not a command that can be run", strings.Join(names, " "))
+	}
+	return names, append(opts, cmd.Options()...), nil
+}
+
+// commandLine builds the command line for a request: the command names, an --option=value for each option
+// in order of name, then -- and the args
+func commandLine(names []string, body Request) ([]string, error) {
+	args := slices.Clone(names)
+	keys := make([]string, 0, len(body.Options))
+	for key := range body.Options {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if key == "" || strings.HasPrefix(key, "-") || strings.Contains(key, "=") {
+			return nil, fmt.Errorf("api: invalid option name %q", key)
+		}
+		value, err := formatValue(body.Options[key], true)
+		if err != nil {
+			return nil, fmt.Errorf("api: option %s: %w", key, err)
+		}
+		if value == "" {
+			// --option= is a missing value on the command line
+			return nil, fmt.Errorf("api: option %s: empty value", key)
+		}
+		args = append(args, "--"+key+"="+value)
+	}
+	args = append(args, "--")
+	for i, arg := range body.Args {
+		value, err := formatValue(arg, false)
+		if err != nil {
+			return nil, fmt.Errorf("api: argument %d: %w", i, err)
+		}
+		args = append(args, value)
+	}
+	return args, nil
+}
+
+// formatValue formats a JSON value as it would be written on the command line.
+// A list, if allowed, becomes a comma-separated list, as for a []string option, so its items cannot contain commas.
+func formatValue(v any, allowList bool) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case json.Number:
+		return val.String(), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case []any:
+		if allowList {
+			items := make([]string, 0, len(val))
+			for _, item := range val {
+				s, err := formatValue(item, false)
+				if err != nil {
+					return "", err
+				}
+				if strings.Contains(s, ",") {
+					return "", fmt.Errorf("list item %q contains a comma", s)
+				}
+				items = append(items, s)
+			}
+			return strings.Join(items, ","), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SpencerBrown/go-http/command"
+	"github.com/SpencerBrown/go-http/option"
+	"github.com/SpencerBrown/go-http/run"
+)
+
+// newTestHandler returns a Handler for app with a greet command, a fail command and a db command with a migrate subcommand
+func newTestHandler() *Handler {
+	opts := option.NewOptions()
+	opts.AddOptionMust(option.NewOptionMust("name", []string{"who"}, 'n', nil, "name", "", true, "world", nil))
+	opts.AddOptionMust(option.NewOptionMust("times", nil, 0, nil, "repeat", "", true, 1, nil))
+	opts.AddOptionMust(option.NewOptionMust("loud", nil, 0, nil, "shout", "", false, false, nil))
+	opts.AddOptionMust(option.NewOptionMust("tags", nil, 0, nil, "tags", "", false, []string{}, nil))
+	greet := command.NewCommandMust("greet", []string{"hi"}, "greet", "", opts)
+	greet.SetHandler(func(ctx context.Context, pc *command.ParsedCommands, env command.Env) error {
+		options := pc.Invoked().Options()
+		name := option.GetParsedValueMust[string](options.GetParsedOption("name"))
+		if option.GetParsedValueMust[bool](options.GetParsedOption("loud")) {
+			name = strings.ToUpper(name)
+		}
+		tags := option.GetParsedValueMust[[]string](options.GetParsedOption("tags"))
+		for range option.GetParsedValueMust[int](options.GetParsedOption("times")) {
+			fmt.Fprintf(env.Stdout(), "hello %s %v %v\n", name, tags, pc.Args())
+		}
+		return nil
+	})
+	fail := command.NewCommandMust("fail", nil, "fail", "", nil)
+	fail.SetHandler(func(ctx context.Context, pc *command.ParsedCommands, env command.Env) error {
+		fmt.Fprintln(env.Stderr(), "about to fail")
+		return run.NewExitError(3, errors.New("failed"))
+	})
+	work := command.NewCommandMust("work", nil, "work with cleanup", "", nil)
+	work.SetHandler(func(ctx context.Context, pc *command.ParsedCommands, env command.Env) error {
+		env.OnShutdown(func(ctx context.Context) error {
+			_, err := fmt.Fprintln(env.Stdout(), "cleaned up")
+			return err
+		})
+		_, err := fmt.Fprintln(env.Stdout(), "working")
+		return err
+	})
+	db := command.NewCommandMust("db", nil, "db", "", nil)
+	db.SetHandler(func(ctx context.Context, pc *command.ParsedCommands, env command.Env) error { return nil })
+	db.AddSubcommandMust(command.NewCommandMust("migrate", nil, "migrate", "", nil))
+	root := command.NewCommandMust("app", nil, "app", "", nil)
+	root.AddPersistentOptionMust(option.NewOptionMust("config", []string{"conf"}, 'c', nil, "config file", "", true, "", nil))
+	root.AddSubcommandMust(greet)
+	root.AddSubcommandMust(fail)
+	root.AddSubcommandMust(work)
+	root.AddSubcommandMust(db)
+	cmds := command.NewCommands()
+	cmds.AddCommandMust(root)
+	return NewHandler(&run.Runner{Commands: &cmds, Args: []string{"prog"}, ConfigOption: "config"})
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		want       Response
+	}{
+		{name: "options and args", path: "/commands/app/hi", body: `{"options": {"who": "api", "times": 2, "loud": true, "tags": ["a", "b"]}, "args": ["x", 1]}`,
+			wantStatus: http.StatusOK, want: Response{Stdout: "hello API [a b] [x 1]\nhello API [a b] [x 1]\n"}},
+		{name: "empty string arg", path: "/commands/app/greet", body: `{"args": ["", "x"]}`,
+			wantStatus: http.StatusOK, want: Response{Stdout: "hello world [] [ x]\n"}},
+		{name: "defaults", path: "/commands/app/greet", body: `{}`,
+			wantStatus: http.StatusOK, want: Response{Stdout: "hello world [] []\n"}},
+		{name: "shutdown hook", path: "/commands/app/work", body: `{}`,
+			wantStatus: http.StatusOK, want: Response{Stdout: "working\ncleaned up\n"}},
+		{name: "handler failure", path: "/commands/app/fail", body: `{}`,
+			wantStatus: http.StatusInternalServerError, want: Response{Stderr: "about to fail\n", ExitCode: 3, Error: "failed"}},
+		{name: "invalid value", path: "/commands/app/greet", body: `{"options": {"times": "many"}}`,
+			wantStatus: http.StatusBadRequest, want: Response{ExitCode: run.ExitUsage, Error: "command.Parse: option --times: could not parse many as int",
+				Stderr: "prog: option --times: could not parse many as int\nRun 'prog app greet --help' for usage.\n"}},
+		{name: "unknown option", path: "/commands/app/greet", body: `{"options": {"bogus": 1}}`,
+			wantStatus: http.StatusBadRequest, want: Response{ExitCode: run.ExitUsage, Error: "command.Parse: unknown option --bogus for command greet",
+				Stderr: "prog: unknown option --bogus for command greet\nRun 'prog app greet --help' for usage.\n"}},
+	}
+	h := newTestHandler()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			var got Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("response %q is not JSON: %v", rec.Body.String(), err)
+			}
+			if got != tt.want {
+				t.Errorf("response = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerRequestErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{name: "method", method: http.MethodGet, path: "/commands/app/greet", wantStatus: http.StatusMethodNotAllowed},
+		{name: "unknown command", method: http.MethodPost, path: "/commands/app/bogus", body: `{}`, wantStatus: http.StatusNotFound},
+		{name: "not a leaf", method: http.MethodPost, path: "/commands/app/db", body: `{}`, wantStatus: http.StatusNotFound},
+		{name: "no handler", method: http.MethodPost, path: "/commands/app/db/migrate", body: `{}`, wantStatus: http.StatusNotFound},
+		{name: "outside prefix", method: http.MethodPost, path: "/other", body: `{}`, wantStatus: http.StatusNotFound},
+		{name: "bad JSON", method: http.MethodPost, path: "/commands/app/greet", body: `{"options": `, wantStatus: http.StatusBadRequest},
+		{name: "unknown field", method: http.MethodPost, path: "/commands/app/greet", body: `{"flags": {}}`, wantStatus: http.StatusBadRequest},
+		{name: "nested value", method: http.MethodPost, path: "/commands/app/greet", body: `{"options": {"name": {"a": 1}}}`, wantStatus: http.StatusBadRequest},
+		{name: "option name", method: http.MethodPost, path: "/commands/app/greet", body: `{"options": {"--name": "x"}}`, wantStatus: http.StatusBadRequest},
+		{name: "comma in list item", method: http.MethodPost, path: "/commands/app/greet", body: `{"options": {"tags": ["a,b", "c"]}}`, wantStatus: http.StatusBadRequest},
+		{name: "empty string", method: http.MethodPost, path: "/commands/app/greet", body: `{"options": {"name": ""}}`, wantStatus: http.StatusBadRequest},
+		{name: "config file", method: http.MethodPost, path: "/commands/app/greet", body: `{"options": {"config": "/etc/passwd"}}`, wantStatus: http.StatusBadRequest},
+		{name: "config file alias", method: http.MethodPost, path: "/commands/app/greet", body: `{"options": {"CONF": "/etc/passwd"}}`, wantStatus: http.StatusBadRequest},
+		{name: "empty list", method: http.MethodPost, path: "/commands/app/greet", body: `{"options": {"tags": []}}`, wantStatus: http.StatusBadRequest},
+	}
+	h := newTestHandler()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, bytes.NewReader([]byte(tt.body))))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("%s %s status = %d, want %d: %s", tt.method, tt.path, rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
@@ -3,23 +3,26 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 
 	"github.com/SpencerBrown/go-http/command"
+	"github.com/SpencerBrown/go-http/completion"
 	"github.com/SpencerBrown/go-http/option"
 	"github.com/SpencerBrown/go-http/run"
+	"github.com/SpencerBrown/go-http/serve"
 )
 
 func main() {
 	ctx := context.Background()
 	r := run.Runner{
-		Commands:    nil,
-		Args:        os.Args,
-		GetEnvVar:   os.Getenv,
-		GetWorkDir:  os.Getwd,
-		Input:       os.Stdin,
-		Output:      os.Stdout,
-		ErrorOutput: os.Stderr,
+		Commands:     nil,
+		Args:         os.Args,
+		LookupEnvVar: os.LookupEnv,
+		GetWorkDir:   os.Getwd,
+		Input:        os.Stdin,
+		Output:       os.Stdout,
+		ErrorOutput:  os.Stderr,
 	}
 
 	opts := option.NewOptions()
@@ -27,12 +30,25 @@ func main() {
 	opts.AddOptionMust(option.NewOptionMust("bar", nil, 'b', nil, "second", "second part of foobar", false, 2, nil))
 	opts.AddOptionMust(option.NewOptionMust("foobar", []string{"fb"}, 0, nil, "is?", "is it?", false, true, nil))
 
+	foobarfoo := command.NewCommandMust("foobarfoo", []string{"fbf"}, "foobar", "foobar command", opts)
+	foobarfoo.SetHandler(showParsed)
+	subfoobar := command.NewCommandMust("subfoobar", []string{"sfb"}, "sub foobar", "sub foobar command", nil)
+	subfoobar.SetHandler(showParsed)
+	foobarfoo.AddSubcommandMust(subfoobar)
+	foobarfoo.AddSubcommandMust(command.NewHelpCommand())
+	foobarfoo.AddSubcommandMust(completion.NewCommand())
+	foobarfoo.AddSubcommandMust(serve.NewCommand(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "hello from foobarfoo\n")
+	})))
+
 	cmds := command.Commands{}
-	cmds.AddCommandMust(command.NewCommandMust("foobarfoo", []string{"fbf"}, "foobar", "foobar command", opts))
-	cmds.AddCommandMust(command.NewCommandMust("subfoobar", []string{"sfb"}, "sub foobar", "sub foobar command", nil))
+	cmds.AddCommandMust(foobarfoo)
 	r.Commands = &cmds
-	if err := r.Run(ctx, true); err != nil {
-		fmt.Fprintf(os.Stderr, "%s\n", err)
-		os.Exit(1)
-	}
+	run.Main(ctx, &r, false)
+}
+
+// showParsed is a command handler that shows what was parsed from the command line
+func showParsed(ctx context.Context, pc *command.ParsedCommands, env command.Env) error {
+	_, err := fmt.Fprint(env.Stdout(), pc.String())
+	return err
 }
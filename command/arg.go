@@ -0,0 +1,257 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/SpencerBrown/go-http/option"
+)
+
+// Arg is the specification of a positional argument of a Command.
+// An argument takes between min and max of the command line arguments, or any number from min up if max is -1.
+// For example min 1, max 1 is a required argument <addr>, min 0, max 1 an optional one [addr],
+// and min 0, max -1 a variadic one [dir...].
+type Arg struct {
+	name        string                      // name of the argument, shown in help
+	description string                      // description of the argument
+	min         int                         // minimum number of values
+	max         int                         // maximum number of values, -1 for no maximum
+	typeName    string                      // name of the type of the values, shown in help
+	convert     func(s string) (any, error) // converts a command line argument to a value
+}
+
+// NewArg creates an argument specification whose values have type V.
+// The name cannot be blank. min must not be negative, and max must be at least min and at least 1, or -1 for no maximum.
+func NewArg[V option.OptionTypes](name string, description string, min int, max int) (*Arg, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) == 0 {
+		return nil, fmt.Errorf("command.NewArg called with blank name")
+	}
+	if min < 0 || (max != -1 && (max < min || max < 1)) {
+		return nil, fmt.Errorf("command.NewArg: argument %s has invalid count %d to %d", name, min, max)
+	}
+	return &Arg{
+		name:        name,
+		description: description,
+		min:         min,
+		max:         max,
+		typeName:    option.TypeNameOf[V](),
+		convert: func(s string) (any, error) {
+			return option.ConvertValue[V](s)
+		},
+	}, nil
+}
+
+// NewArgMust is like NewArg but panics if there is an error.
+func NewArgMust[V option.OptionTypes](name string, description string, min int, max int) *Arg {
+	arg, err := NewArg[V](name, description, min, max)
+	if err != nil {
+		panic(err)
+	}
+	return arg
+}
+
+// Name returns the name of the argument
+func (arg *Arg) Name() string {
+	return arg.name
+}
+
+// Description returns the description of the argument
+func (arg *Arg) Description() string {
+	return arg.description
+}
+
+// Min returns the minimum number of values of the argument
+func (arg *Arg) Min() int {
+	return arg.min
+}
+
+// Max returns the maximum number of values of the argument, or -1 if there is no maximum
+func (arg *Arg) Max() int {
+	return arg.max
+}
+
+// TypeName returns the name of the type of the argument's values, as shown in help output
+func (arg *Arg) TypeName() string {
+	return arg.typeName
+}
+
+// Usage returns the argument as shown in a usage line: <addr>, [addr], <dir>... or [dir...]
+func (arg *Arg) Usage() string {
+	repeated := arg.max == -1 || arg.max > 1
+	switch {
+	case arg.min == 0 && repeated:
+		return "[" + arg.name + "...]"
+	case arg.min == 0:
+		return "[" + arg.name + "]"
+	case repeated:
+		return "<" + arg.name + ">..."
+	}
+	return "<" + arg.name + ">"
+}
+
+// AddArg adds a positional argument to the command, after any it already has.
+// The arguments are filled from the command line in order, each taking as many values as it can
+// while leaving enough for the minimums of the ones after it, so an argument with a variable count
+// may only be followed by optional ones, and one with no maximum must be the last.
+func (cmd *Command) AddArg(arg *Arg) error {
+	if cmd == nil {
+		return fmt.Errorf("command.AddArg called with nil Command")
+	}
+	if arg == nil {
+		return fmt.Errorf("command.AddArg called with nil Arg")
+	}
+	for _, existing := range cmd.args {
+		if existing.name == arg.name {
+			return fmt.Errorf("command.AddArg: command %s already has argument %s", cmd.name, arg.name)
+		}
+		if existing.max == -1 {
+			return fmt.Errorf("command.AddArg: command %s: argument %s cannot follow %s, which has no maximum", cmd.name, arg.name, existing.name)
+		}
+		if existing.min != existing.max && arg.min > 0 {
+			return fmt.Errorf("command.AddArg: command %s: required argument %s cannot follow %s, which is not fixed", cmd.name, arg.name, existing.name)
+		}
+	}
+	cmd.args = append(cmd.args, arg)
+	return nil
+}
+
+// AddArgMust adds a positional argument to the command and panics if there is an error.
+func (cmd *Command) AddArgMust(arg *Arg) {
+	if err := cmd.AddArg(arg); err != nil {
+		panic(err)
+	}
+}
+
+// Args returns the positional arguments of the command, nil if the command takes any arguments unchecked
+func (cmd *Command) Args() []*Arg {
+	return cmd.args
+}
+
+// ArgsUsage returns the arguments of the command as shown in a usage line, such as "<addr> [dir...]",
+// or "[args...]" if the command does not specify its arguments
+func (cmd *Command) ArgsUsage() string {
+	if len(cmd.args) == 0 {
+		return "[args...]"
+	}
+	usage := make([]string, 0, len(cmd.args))
+	for _, arg := range cmd.args {
+		usage = append(usage, arg.Usage())
+	}
+	return strings.Join(usage, " ")
+}
+
+// ParsedArg is a positional argument with the values given for it on the command line
+type ParsedArg struct {
+	arg    *Arg     // the argument specification
+	raw    []string // the command line arguments
+	values []any    // the converted values, of the argument's type
+}
+
+// Name returns the name of the argument
+func (pa *ParsedArg) Name() string {
+	return pa.arg.name
+}
+
+// Arg returns the argument specification
+func (pa *ParsedArg) Arg() *Arg {
+	return pa.arg
+}
+
+// Raw returns the command line arguments given for the argument
+func (pa *ParsedArg) Raw() []string {
+	return pa.raw
+}
+
+// Values returns the converted values of the argument
+func (pa *ParsedArg) Values() []any {
+	return pa.values
+}
+
+// parseArgs checks the number of command line arguments against the argument specifications and converts them.
+// Each argument takes as many values as it can while leaving enough for the minimums of the ones after it.
+// argIndex is the index of the first of args in the command line.
+func parseArgs(cmd *Command, args []string, argIndex int) ([]ParsedArg, error) {
+	minRest := 0
+	for _, arg := range cmd.args {
+		minRest += arg.min
+	}
+	if len(args) < minRest {
+		return nil, &ArgCountError{Command: cmd.name, Usage: cmd.ArgsUsage(), Got: len(args), ArgIndex: -1}
+	}
+	parsed := make([]ParsedArg, 0, len(cmd.args))
+	i := 0
+	for _, arg := range cmd.args {
+		minRest -= arg.min
+		n := len(args) - i - minRest
+		if arg.max != -1 && n > arg.max {
+			n = arg.max
+		}
+		pa := ParsedArg{arg: arg, raw: args[i : i+n], values: make([]any, 0, n)}
+		for j, s := range pa.raw {
+			v, err := arg.convert(s)
+			if err != nil {
+				var ive *option.InvalidValueError
+				if errors.As(err, &ive) {
+					ive.Name = arg.name
+					ive.Token = "argument " + arg.name
+					ive.Source = option.SourceCommandLine
+					ive.ArgIndex = argIndex + i + j
+				}
+				return nil, err
+			}
+			pa.values = append(pa.values, v)
+		}
+		parsed = append(parsed, pa)
+		i += n
+	}
+	if i < len(args) {
+		return nil, &ArgCountError{Command: cmd.name, Usage: cmd.ArgsUsage(), Got: len(args), Unexpected: args[i], ArgIndex: argIndex + i}
+	}
+	return parsed, nil
+}
+
+// LookupArg finds a parsed positional argument of the invoked command by name, or nil if none
+func (pcs *ParsedCommands) LookupArg(name string) *ParsedArg {
+	for i := range pcs.parsedArgs {
+		if pcs.parsedArgs[i].arg.name == name {
+			return &pcs.parsedArgs[i]
+		}
+	}
+	return nil
+}
+
+// ParsedArgs returns the parsed positional arguments of the invoked command, empty if it does not specify any
+func (pcs *ParsedCommands) ParsedArgs() []ParsedArg {
+	return pcs.parsedArgs
+}
+
+// GetArgValue returns the first value of a parsed argument as type V, and false if there is no value of that type
+func GetArgValue[V option.OptionTypes](pcs *ParsedCommands, name string) (V, bool) {
+	var zero V
+	pa := pcs.LookupArg(name)
+	if pa == nil || len(pa.values) == 0 {
+		return zero, false
+	}
+	v, ok := pa.values[0].(V)
+	return v, ok
+}
+
+// GetArgValues returns all the values of a parsed argument as type V, and false if the argument does not have type V
+func GetArgValues[V option.OptionTypes](pcs *ParsedCommands, name string) ([]V, bool) {
+	pa := pcs.LookupArg(name)
+	if pa == nil {
+		return nil, false
+	}
+	values := make([]V, 0, len(pa.values))
+	for _, value := range pa.values {
+		v, ok := value.(V)
+		if !ok {
+			return nil, false
+		}
+		values = append(values, v)
+	}
+	return values, true
+}
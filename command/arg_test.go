@@ -0,0 +1,119 @@
+package command
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseArgs(t *testing.T) {
+	serve := NewCommandMust("serve", nil, "run the server", "", nil)
+	serve.AddArgMust(NewArgMust[string]("addr", "address to listen on", 1, 1))
+	serve.AddArgMust(NewArgMust[int]("port", "port to listen on", 0, 1))
+	serve.AddArgMust(NewArgMust[string]("dir", "directories to serve", 0, -1))
+	wait := NewCommandMust("wait", nil, "wait", "", nil)
+	wait.AddArgMust(NewArgMust[time.Duration]("times", "how long to wait", 1, 2))
+	cmds := NewCommands()
+	cmds.AddCommandMust(serve)
+	cmds.AddCommandMust(wait)
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{name: "required only", args: []string{"serve", "localhost"}, want: map[string][]string{"addr": {"localhost"}, "port": {}, "dir": {}}},
+		{name: "all", args: []string{"serve", "localhost", "80", "a", "b"}, want: map[string][]string{"addr": {"localhost"}, "port": {"80"}, "dir": {"a", "b"}}},
+		{name: "missing", args: []string{"serve"}, wantErr: true},
+		{name: "bad type", args: []string{"serve", "localhost", "eighty"}, wantErr: true},
+		{name: "up to max", args: []string{"wait", "1s", "2s"}, want: map[string][]string{"times": {"1s", "2s"}}},
+		{name: "too many", args: []string{"wait", "1s", "2s", "3s"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pcs, err := Parse(cmds, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%v) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for name, want := range tt.want {
+				pa := pcs.LookupArg(name)
+				if pa == nil || !slices.Equal(pa.Raw(), want) {
+					t.Errorf("argument %s = %v, want %v", name, pa, want)
+				}
+			}
+		})
+	}
+
+	pcs, err := Parse(cmds, []string{"serve", "localhost", "0x50", "a"})
+	if err != nil {
+		t.Fatalf("Parse returned error %v", err)
+	}
+	if port, ok := GetArgValue[int](pcs, "port"); !ok || port != 80 {
+		t.Errorf("GetArgValue(port) = %v, %t, want 80, true", port, ok)
+	}
+	if dirs, ok := GetArgValues[string](pcs, "dir"); !ok || !slices.Equal(dirs, []string{"a"}) {
+		t.Errorf("GetArgValues(dir) = %v, %t, want [a], true", dirs, ok)
+	}
+	if _, ok := GetArgValue[string](pcs, "port"); ok {
+		t.Errorf("GetArgValue[string](port) succeeded for an int argument")
+	}
+
+	pcs, err = Parse(cmds, []string{"serve", "--help"})
+	if err != nil {
+		t.Fatalf("Parse returned error %v", err)
+	}
+	var builder strings.Builder
+	if err := pcs.WriteHelp(&builder, "prog"); err != nil {
+		t.Fatalf("WriteHelp returned error %v", err)
+	}
+	want := `Usage: prog serve [options] <addr> [port] [dir...]
+
+run the server
+
+Arguments:
+  <addr>    string  address to listen on
+  [port]    int     port to listen on
+  [dir...]  string  directories to serve
+
+Options:
+  -h, --help      show help
+`
+	if builder.String() != want {
+		t.Errorf("WriteHelp() =\n%s\nwant\n%s", builder.String(), want)
+	}
+}
+
+func TestAddArg(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []*Arg
+		wantErr bool
+	}{
+		{name: "required after optional", args: []*Arg{NewArgMust[string]("a", "", 0, 1), NewArgMust[string]("b", "", 1, 1)}, wantErr: true},
+		{name: "after unlimited", args: []*Arg{NewArgMust[string]("a", "", 0, -1), NewArgMust[string]("b", "", 0, 1)}, wantErr: true},
+		{name: "duplicate", args: []*Arg{NewArgMust[string]("a", "", 1, 1), NewArgMust[string]("a", "", 1, 1)}, wantErr: true},
+		{name: "optional after optional", args: []*Arg{NewArgMust[string]("a", "", 0, 1), NewArgMust[string]("b", "", 0, 1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := NewCommandMust("cmd", nil, "", "", nil)
+			var err error
+			for _, arg := range tt.args {
+				if err = cmd.AddArg(arg); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AddArg error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if _, err := NewArg[int]("n", "", 2, 1); err == nil {
+		t.Errorf("NewArg with max below min returned no error")
+	}
+}
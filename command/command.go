@@ -1,7 +1,10 @@
 package command
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"slices"
 	"strings"
 	"unicode/utf8"
 
@@ -11,6 +14,7 @@ import (
 
 // Command represents a command or subcommand in a command/subcommand tree. The root of the tree is a Commands, representing a set of Command.
 // options represents the options for this command at this level of the tree
+// persistentOptions represents options that can be given for this command and at any level below it
 // subcommands is a Commands representing the subcommands that can follow this command
 // name is the name of the command
 // alias is a slice of aliases for the command
@@ -20,19 +24,52 @@ import (
 //
 // Arguments start at the first unrecognized token, or after the terminator "--"
 // The --help flag automatically prints out the command syntax and flags
-// The func associated with a Command is what is called when the provided command line maps to this Command
-// The func is given a ParsedCommand with the command line arguments and flags
+// The handler associated with a Command is what is called when the provided command line maps to this Command
+// The handler is given the ParsedCommands with the command line arguments and flags
 type Command struct {
-	name            string         // Name of command
-	alias           []string       // Aliases for command
-	description     string         // Description of command
-	longDescription string         // Long description of command
-	options         option.Options // Flags for this command
-	subcommands     Commands       // Subcommands that can follow this command
+	name              string          // Name of command
+	alias             []string        // Aliases for command
+	description       string          // Description of command
+	longDescription   string          // Long description of command
+	options           option.Options  // Flags for this command
+	persistentOptions option.Options  // Flags for this command that are inherited by all its subcommands
+	subcommands       Commands        // Subcommands that can follow this command
+	handler           CommandHandler  // Handler to call when this is the last command on the command line, or nil if none
+	argsCompleter     ArgsCompleter   // Function that offers args for shell completion, or nil if none
+	groups            []*option.Group // Constraints on which of the command's options may be given together
+	args              []*Arg          // Positional arguments, or nil if the arguments are not checked
+	persistentPreRun  CommandHandler  // Hook run before the handler of this command or any command below it
+	preRun            CommandHandler  // Hook run before the handler of this command
+	postRun           CommandHandler  // Hook run after the handler of this command
+	persistentPostRun CommandHandler  // Hook run after the handler of this command or any command below it
 }
 
-// Commands is a set of Command representing a set of commands at this level of the command tree
-type Commands map[string](*Command)
+// ArgsCompleter returns the candidates for completing a command line argument of a Command during shell completion,
+// given the command line parsed so far and the part of the argument already typed.
+type ArgsCompleter func(pc *ParsedCommands, prefix string) []option.Completion
+
+// CommandHandler is a function that is called when the command line maps to a Command.
+// It is given the parsed command line and the environment to run in.
+// It returns an error if the command failed.
+type CommandHandler func(ctx context.Context, pc *ParsedCommands, env Env) error
+
+// ShutdownHook is a function run after the handler finishes, to release what the program holds.
+type ShutdownHook func(ctx context.Context) error
+
+// Env is the environment a CommandHandler runs in: input and output streams,
+// environment variables, the working directory and the hooks to run at shutdown. run.Runner implements Env.
+type Env interface {
+	Stdin() io.Reader             // The input stream
+	Stdout() io.Writer            // The output stream
+	Stderr() io.Writer            // The error output stream
+	Getenv(name string) string    // Get an environment variable
+	Getwd() (string, error)       // Get the working directory
+	OnShutdown(hook ShutdownHook) // Register a hook to run after the handler finishes
+}
+
+// Commands is a set of Command representing a set of commands at this level of the command tree.
+// The commands are kept in the order they were added, which is the order of help, documentation and String output.
+type Commands []*Command
 
 // Name returns the command name
 func (cmd *Command) Name() string {
@@ -59,11 +96,78 @@ func (cmd *Command) Options() option.Options {
 	return cmd.options
 }
 
+// PersistentOptions returns the persistent Options for the command, which are inherited by all its subcommands
+func (cmd *Command) PersistentOptions() option.Options {
+	return cmd.persistentOptions
+}
+
 // Subcommands returns the subcommands for the command
 func (cmd *Command) Subcommands() Commands {
 	return cmd.subcommands
 }
 
+// Handler returns the command handler, or nil if none
+func (cmd *Command) Handler() CommandHandler {
+	return cmd.handler
+}
+
+// SetHandler sets the handler that is called when the command line maps to this command
+func (cmd *Command) SetHandler(handler CommandHandler) {
+	cmd.handler = handler
+}
+
+// PersistentPreRun returns the hook run before the handler of this command or any command below it, or nil if none
+func (cmd *Command) PersistentPreRun() CommandHandler {
+	return cmd.persistentPreRun
+}
+
+// SetPersistentPreRun sets a hook run before the handler of this command or any command below it,
+// for setup that the whole subtree needs. Persistent pre-run hooks run from the top of the tree down to the invoked command.
+func (cmd *Command) SetPersistentPreRun(hook CommandHandler) {
+	cmd.persistentPreRun = hook
+}
+
+// PreRun returns the hook run before the handler of this command, or nil if none
+func (cmd *Command) PreRun() CommandHandler {
+	return cmd.preRun
+}
+
+// SetPreRun sets a hook run before the handler of this command, after the persistent pre-run hooks
+func (cmd *Command) SetPreRun(hook CommandHandler) {
+	cmd.preRun = hook
+}
+
+// PostRun returns the hook run after the handler of this command, or nil if none
+func (cmd *Command) PostRun() CommandHandler {
+	return cmd.postRun
+}
+
+// SetPostRun sets a hook run after the handler of this command, before the persistent post-run hooks
+func (cmd *Command) SetPostRun(hook CommandHandler) {
+	cmd.postRun = hook
+}
+
+// PersistentPostRun returns the hook run after the handler of this command or any command below it, or nil if none
+func (cmd *Command) PersistentPostRun() CommandHandler {
+	return cmd.persistentPostRun
+}
+
+// SetPersistentPostRun sets a hook run after the handler of this command or any command below it,
+// for teardown of what the persistent pre-run hook set up. Persistent post-run hooks run from the invoked command up to the top.
+func (cmd *Command) SetPersistentPostRun(hook CommandHandler) {
+	cmd.persistentPostRun = hook
+}
+
+// ArgsCompleter returns the function that offers args for shell completion, or nil if none
+func (cmd *Command) ArgsCompleter() ArgsCompleter {
+	return cmd.argsCompleter
+}
+
+// SetArgsCompleter sets the function that offers args for shell completion
+func (cmd *Command) SetArgsCompleter(completer ArgsCompleter) {
+	cmd.argsCompleter = completer
+}
+
 // NewCommand creates a new command with the given name, aliases, descriptions, and options
 // command name and any aliases cannot be blank, and cannot duplicate each other
 // command name and aliases are case insensitive and can include unicode characters
@@ -145,7 +249,7 @@ func (cmds *Commands) AddCommand(cmd *Command) error {
 		}
 		dupNameCheck[str] = struct{}{}
 	}
-	(*cmds)[cmd.name] = cmd // add the Command to the Commands
+	*cmds = append(*cmds, cmd) // add the Command to the Commands
 	return nil
 }
 
@@ -156,6 +260,102 @@ func (cmds *Commands) AddCommandMust(cmd *Command) {
 	}
 }
 
+// AddPersistentOption adds an option to the command that can also be given at any level below it.
+// The value of a persistent option is shared by the command and all its subcommands.
+// It returns an error if the option conflicts with the command's own or persistent options.
+// Conflicts with options of subcommands are checked by Validate.
+func (cmd *Command) AddPersistentOption(opt *option.Option) error {
+	if cmd == nil {
+		return fmt.Errorf("command.AddPersistentOption called with nil Command")
+	}
+	if opt == nil {
+		return fmt.Errorf("command.AddPersistentOption called with nil Option")
+	}
+	all := option.NewOptions()
+	all = append(all, cmd.options...)
+	all = append(all, cmd.persistentOptions...)
+	if err := all.AddOption(opt); err != nil {
+		return fmt.Errorf("command.AddPersistentOption: command %s: %w", cmd.name, err)
+	}
+	cmd.persistentOptions = append(cmd.persistentOptions, opt)
+	return nil
+}
+
+// AddPersistentOptionMust adds a persistent option to the command and panics if there is an error.
+func (cmd *Command) AddPersistentOptionMust(opt *option.Option) {
+	if err := cmd.AddPersistentOption(opt); err != nil {
+		panic(err)
+	}
+}
+
+// AddOptionGroup adds a constraint on which of the command's options may be given together,
+// checked after all the sources of option values are applied.
+// The options must be the command's own or persistent options, and may be named by alias.
+func (cmd *Command) AddOptionGroup(kind option.GroupKind, names ...string) error {
+	if cmd == nil {
+		return fmt.Errorf("command.AddOptionGroup called with nil Command")
+	}
+	own := option.NewOptions()
+	own = append(own, cmd.options...)
+	own = append(own, cmd.persistentOptions...)
+	optNames := make([]string, 0, len(names))
+	for _, name := range names {
+		opt := option.GetOptionByName(own, name)
+		if opt == nil {
+			return fmt.Errorf("command.AddOptionGroup: command %s has no option %s", cmd.name, name)
+		}
+		optNames = append(optNames, opt.Name())
+	}
+	g, err := option.NewGroup(kind, optNames...)
+	if err != nil {
+		return fmt.Errorf("command.AddOptionGroup: command %s: %w", cmd.name, err)
+	}
+	cmd.groups = append(cmd.groups, g)
+	return nil
+}
+
+// AddOptionGroupMust adds an option group to the command and panics if there is an error.
+func (cmd *Command) AddOptionGroupMust(kind option.GroupKind, names ...string) {
+	if err := cmd.AddOptionGroup(kind, names...); err != nil {
+		panic(err)
+	}
+}
+
+// OptionGroups returns the constraints on the command's options
+func (cmd *Command) OptionGroups() []*option.Group {
+	return cmd.groups
+}
+
+// Validate checks a tree of Commands for option conflicts.
+// The options of each command, together with the persistent options of the command and all its ancestors,
+// must not share any names, aliases, short names or short aliases.
+func Validate(cmds Commands) error {
+	return validateCommands(cmds, option.NewOptions())
+}
+
+// validateCommands checks each command in cmds against the persistent options inherited from its ancestors
+func validateCommands(cmds Commands, inherited option.Options) error {
+	for _, cmd := range cmds {
+		all := option.NewOptions()
+		all = append(all, inherited...)
+		own := option.NewOptions()
+		own = append(own, cmd.persistentOptions...)
+		own = append(own, cmd.options...)
+		for _, opt := range own {
+			if err := all.AddOption(opt); err != nil {
+				return fmt.Errorf("command.Validate: command %s: %w", cmd.name, err)
+			}
+		}
+		persistent := option.NewOptions()
+		persistent = append(persistent, inherited...)
+		persistent = append(persistent, cmd.persistentOptions...)
+		if err := validateCommands(cmd.subcommands, persistent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // AddSubcommand adds a Command as a subcommand to this Command
 func (cmd *Command) AddSubcommand(subcmd *Command) error {
 	if cmd == nil {
@@ -177,6 +377,41 @@ func (cmd *Command) AddSubcommandMust(subcmd *Command) {
 	}
 }
 
+// GetCommandByName gets a command by name from a Commands, returning nil if the command does not exist.
+// The name is case insensitive and whitespace is trimmed.
+// It can match either the name or any alias of the command.
+func GetCommandByName(cmds Commands, name string) *Command {
+	trimmedName := strings.ToLower(strings.TrimSpace(name))
+	for _, cmd := range cmds {
+		if cmd.name == trimmedName || slices.Contains(cmd.alias, trimmedName) {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// Walk calls fn for every command in the tree, in the order the commands were added,
+// with a parent before its subcommands. fn is given the path of commands from the top of the tree
+// to the command; the path slice is only valid during the call.
+// Walk stops and returns the error if fn returns one.
+func (cmds Commands) Walk(fn func(path []*Command) error) error {
+	return walkCommands(cmds, make([]*Command, 0), fn)
+}
+
+// walkCommands walks cmds, which are below the commands in path
+func walkCommands(cmds Commands, path []*Command, fn func(path []*Command) error) error {
+	for _, cmd := range cmds {
+		cmdPath := append(path, cmd)
+		if err := fn(cmdPath); err != nil {
+			return err
+		}
+		if err := walkCommands(cmd.subcommands, cmdPath, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // String returns a string representation of the Commands and all subcommands
 // we loop through each command and its subcommands recursively,
 // indenting each level for readability
@@ -190,13 +425,11 @@ func (cmds *Commands) String() string {
 	return builder.String()
 }
 
+// showCommands appends the string representation of each Command and, indented below it, its subcommands
 func showCommands(builder *strings.Builder, cmds *Commands, indent int) {
 	for _, cmd := range *cmds {
 		cmd.showCommand(builder, indent)
-		for _, subcmd := range cmd.subcommands {
-			subcmd.showCommand(builder, indent+1)
-			showCommands(builder, &subcmd.subcommands, indent+1)
-		}
+		showCommands(builder, &cmd.subcommands, indent+1)
 	}
 }
 
@@ -206,9 +439,12 @@ func (cmd *Command) showCommand(builder *strings.Builder, indent int) {
 		builder.WriteString("Command: nil\n")
 		return
 	}
-	builder.WriteString("Command: " + cmd.name + "\n")
-	builder.WriteString(util.Indent(fmt.Sprintf("Aliases: %s\n", strings.Join(cmd.alias, "'")), indent+1))
+	builder.WriteString(util.Indent("Command: "+cmd.name+"\n", indent))
+	builder.WriteString(util.Indent(fmt.Sprintf("Aliases: %s\n", strings.Join(cmd.alias, ", ")), indent+1))
 	builder.WriteString(util.Indent(fmt.Sprintf("Description: %s\n", cmd.description), indent+1))
 	builder.WriteString(util.Indent(fmt.Sprintf("Long Description: %s\n", cmd.longDescription), indent+1))
 	builder.WriteString(util.Indent(cmd.options.String(), indent+1))
+	if len(cmd.persistentOptions) > 0 {
+		builder.WriteString(util.Indent("Persistent "+cmd.persistentOptions.String(), indent+1))
+	}
 }
@@ -0,0 +1,77 @@
+package command
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// orderedCommands returns commands added out of alphabetical order, with subcommands two levels deep
+func orderedCommands() Commands {
+	zeta := NewCommandMust("zeta", []string{"z"}, "last letter", "", nil)
+	mid := NewCommandMust("mid", nil, "in the middle", "", nil)
+	mid.AddSubcommandMust(NewCommandMust("leaf", nil, "a leaf", "", nil))
+	zeta.AddSubcommandMust(mid)
+	zeta.AddSubcommandMust(NewCommandMust("beta", nil, "second letter", "", nil))
+	cmds := NewCommands()
+	cmds.AddCommandMust(zeta)
+	cmds.AddCommandMust(NewCommandMust("alpha", nil, "first letter", "", nil))
+	return cmds
+}
+
+func TestCommandsWalk(t *testing.T) {
+	cmds := orderedCommands()
+	var paths []string
+	err := cmds.Walk(func(path []*Command) error {
+		names := make([]string, 0)
+		for _, cmd := range path {
+			names = append(names, cmd.Name())
+		}
+		paths = append(paths, strings.Join(names, " "))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk returned error %v", err)
+	}
+	want := []string{"zeta", "zeta mid", "zeta mid leaf", "zeta beta", "alpha"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("Walk visited %v, want %v", paths, want)
+	}
+
+	stop := errors.New("stop")
+	count := 0
+	err = cmds.Walk(func(path []*Command) error {
+		count++
+		if path[len(path)-1].Name() == "mid" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || count != 2 {
+		t.Errorf("Walk returned %v after %d calls, want %v after 2", err, count, stop)
+	}
+}
+
+func TestCommandsString(t *testing.T) {
+	cmds := orderedCommands()
+	got := cmds.String()
+	for i := 0; i < 10; i++ {
+		if s := cmds.String(); s != got {
+			t.Fatalf("String() changed between calls:\n%s\nthen\n%s", got, s)
+		}
+	}
+	var lines []string
+	for _, line := range strings.Split(got, "\n") {
+		if strings.Contains(line, "Command:") {
+			lines = append(lines, line)
+		}
+	}
+	want := []string{"Command: zeta", "  Command: mid", "    Command: leaf", "  Command: beta", "Command: alpha"}
+	if !slices.Equal(lines, want) {
+		t.Errorf("String() commands = %q, want %q", lines, want)
+	}
+	if cmd := GetCommandByName(cmds, " Z "); cmd == nil || cmd.Name() != "zeta" {
+		t.Errorf("GetCommandByName(z) = %v, want zeta", cmd)
+	}
+}
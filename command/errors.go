@@ -0,0 +1,156 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SpencerBrown/go-http/option"
+)
+
+// ParseError is returned by Parse when the command line, environment or configuration file does not fit the command tree,
+// a usage error as opposed to a failure such as an unreadable configuration file.
+// Err is one of the error types below, or an *option.InvalidValueError or *option.ConstraintError, and can be found with errors.As.
+type ParseError struct {
+	Path []string // names of the commands invoked before the error, from the top of the tree
+	Err  error    // what was wrong
+}
+
+// Error returns the message of Err
+func (e *ParseError) Error() string {
+	return "command.Parse: " + e.Err.Error()
+}
+
+// Unwrap returns Err
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// UnknownOptionError is an option that the command does not have, or an option before any command.
+type UnknownOptionError struct {
+	Command  string // name of the command, "" if the option came before any command
+	Token    string // the option as given, such as --bogus or -x, or the key in a configuration file
+	ArgIndex int    // index of the command line argument, or -1 if the option is in a configuration file
+	Origin   string // the configuration file and line, such as app.conf:3, or "" if the option is on the command line
+}
+
+// Error returns the message
+func (e *UnknownOptionError) Error() string {
+	switch {
+	case e.Origin != "":
+		return fmt.Sprintf("%s: unknown option %s for command %s", e.Origin, e.Token, e.Command)
+	case e.Command == "":
+		return fmt.Sprintf("option %s must follow a command", e.Token)
+	}
+	return fmt.Sprintf("unknown option %s for command %s", e.Token, e.Command)
+}
+
+// UnknownSectionError is a section of a configuration file that is not the path of a command in the tree.
+type UnknownSectionError struct {
+	Section []string // the command path named by the section
+	Origin  string   // the configuration file and line of a key in the section, such as app.conf:3
+}
+
+// Error returns the message
+func (e *UnknownSectionError) Error() string {
+	return fmt.Sprintf("%s: section %s is not a command", e.Origin, strings.Join(e.Section, "."))
+}
+
+// ConfigOptionError is the option naming the configuration file set within the file, where it would have no effect.
+type ConfigOptionError struct {
+	Key    string // the key as given in the file
+	Origin string // the configuration file and line, such as app.conf:3
+}
+
+// Error returns the message
+func (e *ConfigOptionError) Error() string {
+	return fmt.Sprintf("%s: option %s names the configuration file and cannot be set in it", e.Origin, e.Key)
+}
+
+// MissingValueError is an option that takes a value given without one, or with an empty one.
+type MissingValueError struct {
+	Option   *option.Option // the option
+	Token    string         // the option as given, such as --port or -p
+	ArgIndex int            // index of the command line argument
+	Empty    bool           // true if the value was given but empty, as in --port=
+}
+
+// Error returns the message
+func (e *MissingValueError) Error() string {
+	if e.Empty {
+		return fmt.Sprintf("option %s has an empty value", e.Token)
+	}
+	return fmt.Sprintf("option %s requires a value", e.Token)
+}
+
+// UnknownCommandError is a name that is not a command, in strict mode where a command is expected.
+type UnknownCommandError struct {
+	Parent      string   // name of the command it should be a subcommand of, "" at the top level
+	Name        string   // the name as given
+	ArgIndex    int      // index of the command line argument
+	Suggestions []string // names and aliases that are close to Name, closest first
+}
+
+// Error returns the message, with any suggestions
+func (e *UnknownCommandError) Error() string {
+	msg := fmt.Sprintf("unknown command %s", e.Name)
+	if e.Parent != "" {
+		msg += " for command " + e.Parent
+	}
+	if len(e.Suggestions) > 0 {
+		msg += ", did you mean " + strings.Join(e.Suggestions, " or ") + "?"
+	}
+	return msg
+}
+
+// MissingOption is a required option that was not given, with the path of the command it belongs to.
+type MissingOption struct {
+	Path   []string       // names of the commands from the top of the tree to the command that has the option
+	Option *option.Option // the option
+}
+
+// MissingOptionsError lists all the required options that were not given.
+type MissingOptionsError struct {
+	Missing []MissingOption
+}
+
+// Error returns the message listing the missing options
+func (e *MissingOptionsError) Error() string {
+	missing := make([]string, 0, len(e.Missing))
+	for _, m := range e.Missing {
+		missing = append(missing, fmt.Sprintf("--%s for command %s", m.Option.Name(), strings.Join(m.Path, " ")))
+	}
+	return "missing required options: " + strings.Join(missing, ", ")
+}
+
+// GroupError is an option group of a command whose constraint is broken.
+type GroupError struct {
+	Path []string                // names of the commands from the top of the tree to the command that has the group
+	Err  *option.ConstraintError // the broken constraint
+}
+
+// Error returns the message, naming the command
+func (e *GroupError) Error() string {
+	return fmt.Sprintf("command %s: %s", strings.Join(e.Path, " "), e.Err)
+}
+
+// Unwrap returns the broken constraint
+func (e *GroupError) Unwrap() error {
+	return e.Err
+}
+
+// ArgCountError is a number of positional arguments that does not fit the command's argument specifications.
+type ArgCountError struct {
+	Command    string // name of the command
+	Usage      string // the arguments of the command as shown in a usage line
+	Got        int    // the number of arguments given
+	Unexpected string // the first argument beyond the maximum, or "" if there are too few
+	ArgIndex   int    // index of the command line argument of Unexpected, or -1 if there are too few
+}
+
+// Error returns the message
+func (e *ArgCountError) Error() string {
+	if e.Unexpected != "" {
+		return fmt.Sprintf("command %s takes %s, got unexpected argument %s", e.Command, e.Usage, e.Unexpected)
+	}
+	return fmt.Sprintf("command %s takes %s, got %d arguments", e.Command, e.Usage, e.Got)
+}
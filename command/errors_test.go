@@ -0,0 +1,101 @@
+package command
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/SpencerBrown/go-http/option"
+)
+
+func TestParseErrors(t *testing.T) {
+	root := NewCommandMust("app", nil, "the app", "", nil)
+	opts := option.NewOptions()
+	port := option.NewOptionMust("port", nil, 'p', nil, "port", "", true, 8080, nil)
+	port.SetEnvVars("PORT")
+	opts.AddOptionMust(port)
+	opts.AddOptionMust(option.NewOptionMust("quiet", nil, 'q', nil, "quiet", "", false, false, nil))
+	opts.AddOptionMust(option.NewOptionMust("verbose", nil, 'v', nil, "verbose", "", false, false, nil))
+	serve := NewCommandMust("serve", nil, "serve", "", opts)
+	serve.AddOptionGroupMust(option.MutuallyExclusive, "quiet", "verbose")
+	serve.AddArgMust(NewArgMust[int]("count", "count", 0, 1))
+	root.AddSubcommandMust(serve)
+	cmds := NewCommands()
+	cmds.AddCommandMust(root)
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		check    func(err error) bool
+		wantPath []string
+	}{
+		{name: "unknown long option", args: []string{"app", "serve", "--bogus"}, wantPath: []string{"app", "serve"},
+			check: func(err error) bool {
+				var e *UnknownOptionError
+				return errors.As(err, &e) && e.Token == "--bogus" && e.Command == "serve" && e.ArgIndex == 2
+			}},
+		{name: "option before command", args: []string{"-x"}, wantPath: []string{},
+			check: func(err error) bool {
+				var e *UnknownOptionError
+				return errors.As(err, &e) && e.Command == "" && e.ArgIndex == 0
+			}},
+		{name: "invalid value", args: []string{"app", "serve", "-p", "lots"}, wantPath: []string{"app", "serve"},
+			check: func(err error) bool {
+				var e *option.InvalidValueError
+				return errors.As(err, &e) && e.Name == "port" && e.Token == "option -p" && e.Value == "lots" && e.ArgIndex == 2
+			}},
+		{name: "missing value", args: []string{"app", "serve", "--port"}, wantPath: []string{"app", "serve"},
+			check: func(err error) bool {
+				var e *MissingValueError
+				return errors.As(err, &e) && e.Option.Name() == "port" && !e.Empty && e.ArgIndex == 2
+			}},
+		{name: "empty value", args: []string{"app", "serve", "-p="}, wantPath: []string{"app", "serve"},
+			check: func(err error) bool {
+				var e *MissingValueError
+				return errors.As(err, &e) && e.Token == "-p" && e.Empty
+			}},
+		{name: "unknown command", args: []string{"app", "sevre"}, wantPath: []string{"app"},
+			check: func(err error) bool {
+				var e *UnknownCommandError
+				return errors.As(err, &e) && e.Parent == "app" && e.Name == "sevre" && e.ArgIndex == 1 && slices.Equal(e.Suggestions, []string{"serve"})
+			}},
+		{name: "group", args: []string{"app", "serve", "-qv"}, wantPath: []string{"app", "serve"},
+			check: func(err error) bool {
+				var e *option.ConstraintError
+				var ge *GroupError
+				return errors.As(err, &e) && e.Kind == option.MutuallyExclusive && errors.As(err, &ge) && slices.Equal(ge.Path, []string{"app", "serve"})
+			}},
+		{name: "argument value", args: []string{"app", "serve", "-p", "1", "many"}, wantPath: []string{"app", "serve"},
+			check: func(err error) bool {
+				var e *option.InvalidValueError
+				return errors.As(err, &e) && e.Name == "count" && e.ArgIndex == 4
+			}},
+		{name: "argument count", args: []string{"app", "serve", "-p", "1", "1", "2"}, wantPath: []string{"app", "serve"},
+			check: func(err error) bool {
+				var e *ArgCountError
+				return errors.As(err, &e) && e.Unexpected == "2" && e.ArgIndex == 5
+			}},
+		{name: "environment", args: []string{"app", "serve"}, env: map[string]string{"PORT": "x"}, wantPath: []string{"app", "serve"},
+			check: func(err error) bool {
+				var e *option.InvalidValueError
+				return errors.As(err, &e) && e.Source == option.SourceEnv && e.Token == "environment variable PORT" && e.ArgIndex == -1
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := Parser{Strict: true, GetEnvVar: func(name string) string { return tt.env[name] }}
+			_, err := parser.Parse(cmds, tt.args)
+			if err == nil {
+				t.Fatalf("Parse(%v) returned no error", tt.args)
+			}
+			if !tt.check(err) {
+				t.Errorf("Parse(%v) error %#v does not match", tt.args, err)
+			}
+			var pe *ParseError
+			if !errors.As(err, &pe) || !slices.Equal(pe.Path, tt.wantPath) {
+				t.Errorf("Parse(%v) error %v is not a ParseError with path %v", tt.args, err, tt.wantPath)
+			}
+		})
+	}
+}
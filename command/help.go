@@ -0,0 +1,141 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/SpencerBrown/go-http/option"
+)
+
+// WriteHelp writes help for the last invoked command to w: a usage line built from the invoked path,
+// the descriptions of the command, its subcommands with their aliases, and a table of its options
+// followed by the persistent options it inherits.
+// If no command was invoked, it writes help for the top level commands.
+// progName is the name of the program, shown at the start of the usage line, or "" if none.
+func (pcs *ParsedCommands) WriteHelp(w io.Writer, progName string) error {
+	var builder strings.Builder
+	usage := make([]string, 0)
+	if progName != "" {
+		usage = append(usage, progName)
+	}
+	for _, pc := range pcs.commands {
+		usage = append(usage, pc.invokedName)
+	}
+	invoked := pcs.Invoked()
+	if invoked == nil {
+		usage = append(usage, "<command>", "[args...]")
+		fmt.Fprintf(&builder, "Usage: %s\n", strings.Join(usage, " "))
+		writeCommandList(&builder, pcs.root)
+		_, err := io.WriteString(w, builder.String())
+		return err
+	}
+	cmd := invoked.cmd
+	usage = append(usage, "[options]")
+	if len(cmd.subcommands) > 0 {
+		usage = append(usage, "[command]")
+	}
+	usage = append(usage, cmd.ArgsUsage())
+	fmt.Fprintf(&builder, "Usage: %s\n", strings.Join(usage, " "))
+	if cmd.description != "" {
+		fmt.Fprintf(&builder, "\n%s\n", cmd.description)
+	}
+	if cmd.longDescription != "" {
+		fmt.Fprintf(&builder, "\n%s\n", cmd.longDescription)
+	}
+	writeCommandList(&builder, cmd.subcommands)
+	writeArgTable(&builder, cmd.args)
+	// the command's own options, including its persistent options, then the ones it inherits
+	own := option.NewOptions()
+	own = append(own, cmd.options...)
+	own = append(own, cmd.persistentOptions...)
+	inherited := option.NewOptions()
+	for _, opt := range invoked.persistent {
+		if !slices.Contains(own, opt) {
+			inherited = append(inherited, opt)
+		}
+	}
+	withHelp := isHelp(invoked, "--help") || isHelp(invoked, "-h")
+	writeOptionTable(&builder, "Options", own, withHelp)
+	writeOptionTable(&builder, "Inherited options", inherited, false)
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// NewHelpCommand creates a help command: "help <command path>" writes the help for the command at the path
+// below the command that help is added to, or for the top level commands if help is added there.
+// An unknown command in the path is an error with suggestions, a *ParseError that run.Runner reports as a usage error.
+func NewHelpCommand() *Command {
+	cmd := NewCommandMust("help", nil, "show help for a command", "Help shows the usage, subcommands and options of the command named by the arguments.", nil)
+	cmd.SetHandler(helpHandler)
+	return cmd
+}
+
+// helpHandler parses the path of the commands before help followed by the args, and writes help for the result
+func helpHandler(ctx context.Context, pc *ParsedCommands, env Env) error {
+	path := make([]string, 0)
+	for _, parent := range pc.commands[:len(pc.commands)-1] {
+		path = append(path, parent.invokedName)
+	}
+	path = append(path, pc.args...)
+	parser := Parser{Strict: true, ProgName: pc.progName, Partial: true}
+	target, err := parser.Parse(pc.root, path)
+	if err != nil {
+		return err
+	}
+	return target.WriteHelp(env.Stdout(), pc.progName)
+}
+
+// writeCommandList writes the list of commands with their aliases and descriptions, if there are any
+func writeCommandList(builder *strings.Builder, cmds Commands) {
+	if len(cmds) == 0 {
+		return
+	}
+	builder.WriteString("\nCommands:\n")
+	w := tabwriter.NewWriter(builder, 0, 0, 2, ' ', 0)
+	for _, cmd := range cmds {
+		names := append([]string{cmd.name}, cmd.alias...)
+		fmt.Fprintf(w, "  %s\t%s\n", strings.Join(names, ", "), cmd.description)
+	}
+	w.Flush()
+}
+
+// writeArgTable writes an aligned table of positional arguments with their names, type and description, if there are any
+func writeArgTable(builder *strings.Builder, args []*Arg) {
+	if len(args) == 0 {
+		return
+	}
+	builder.WriteString("\nArguments:\n")
+	w := tabwriter.NewWriter(builder, 0, 0, 2, ' ', 0)
+	for _, arg := range args {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", arg.Usage(), arg.typeName, arg.description)
+	}
+	w.Flush()
+}
+
+// writeOptionTable writes an aligned table of options with their names, type, default and description, if there are any.
+// withHelp adds the built-in --help option.
+func writeOptionTable(builder *strings.Builder, title string, opts option.Options, withHelp bool) {
+	if len(opts) == 0 && !withHelp {
+		return
+	}
+	fmt.Fprintf(builder, "\n%s:\n", title)
+	w := tabwriter.NewWriter(builder, 0, 0, 2, ' ', 0)
+	for _, opt := range opts {
+		def := ""
+		if d := opt.DefaultString(); d != "" {
+			def = "(default " + d + ")"
+		}
+		if opt.IsRequired() {
+			def = "(required)"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", strings.Join(opt.CommandLineNames(), ", "), opt.TypeName(), def, opt.Description())
+	}
+	if withHelp {
+		fmt.Fprintf(w, "  %s\t\t\t%s\n", "-h, --help", "show help")
+	}
+	w.Flush()
+}
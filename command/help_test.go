@@ -0,0 +1,161 @@
+package command
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/SpencerBrown/go-http/option"
+)
+
+func TestWriteHelp(t *testing.T) {
+	root := NewCommandMust("app", nil, "the app", "", nil)
+	root.AddPersistentOptionMust(option.NewOptionMust("verbose", nil, 'v', nil, "more output", "", false, false, nil))
+	opts := option.NewOptions()
+	opts.AddOptionMust(option.NewOptionMust("port", []string{"listen"}, 'p', nil, "port to listen on", "", true, 8080, nil))
+	opts.AddOptionMust(option.NewOptionMust("hosts", nil, 0, nil, "allowed hosts", "", true, []string{"a", "b"}, nil))
+	serve := NewCommandMust("serve", []string{"srv"}, "run the server", "Serve runs the server until interrupted.", opts)
+	serve.AddSubcommandMust(NewCommandMust("status", []string{"st"}, "show status", "", nil))
+	serve.AddSubcommandMust(NewCommandMust("reload", nil, "reload config", "", nil))
+	root.AddSubcommandMust(serve)
+	cmds := NewCommands()
+	cmds.AddCommandMust(root)
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "subcommand",
+			args: []string{"app", "srv", "--port=1", "--help", "--bogus"},
+			want: `Usage: prog app srv [options] [command] [args...]
+
+run the server
+
+Serve runs the server until interrupted.
+
+Commands:
+  status, st  show status
+  reload      reload config
+
+Options:
+  -p, --port, --listen  int       (default 8080)  port to listen on
+  --hosts               []string  (default a,b)   allowed hosts
+  -h, --help                                      show help
+
+Inherited options:
+  -v, --verbose  bool    more output
+`,
+		},
+		{
+			name: "top level",
+			args: []string{"-h"},
+			want: `Usage: prog <command> [args...]
+
+Commands:
+  app  the app
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pcs, err := Parse(cmds, tt.args)
+			if err != nil {
+				t.Fatalf("Parse(%v) returned error %v", tt.args, err)
+			}
+			if !pcs.HelpRequested() {
+				t.Fatalf("Parse(%v) did not request help", tt.args)
+			}
+			var builder strings.Builder
+			if err := pcs.WriteHelp(&builder, "prog"); err != nil {
+				t.Fatalf("WriteHelp returned error %v", err)
+			}
+			if builder.String() != tt.want {
+				t.Errorf("WriteHelp() =\n%s\nwant\n%s", builder.String(), tt.want)
+			}
+		})
+	}
+
+	// a command's own -h option takes precedence over help
+	hopts := option.NewOptions()
+	hopts.AddOptionMust(option.NewOptionMust("host", nil, 'h', nil, "host", "", true, "", nil))
+	cmds.AddCommandMust(NewCommandMust("client", nil, "client", "", hopts))
+	pcs, err := Parse(cmds, []string{"client", "-h", "example.com"})
+	if err != nil || pcs.HelpRequested() {
+		t.Errorf("Parse with a -h option: help = %v, error = %v", pcs.HelpRequested(), err)
+	}
+}
+
+func TestStrictAndHelpCommand(t *testing.T) {
+	root := NewCommandMust("app", nil, "the app", "", nil)
+	serve := NewCommandMust("serve", []string{"srv"}, "run the server", "", nil)
+	serve.AddSubcommandMust(NewCommandMust("status", nil, "show status", "", nil))
+	root.AddSubcommandMust(serve)
+	root.AddSubcommandMust(NewCommandMust("server-info", nil, "server info", "", nil))
+	root.AddSubcommandMust(NewHelpCommand())
+	cmds := NewCommands()
+	cmds.AddCommandMust(root)
+
+	strict := Parser{Strict: true, ProgName: "prog"}
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "typo", args: []string{"app", "sevre"}, wantErr: "unknown command sevre for command app, did you mean serve?"},
+		{name: "prefix", args: []string{"app", "serv"}, wantErr: "did you mean serve or srv or server-info?"},
+		{name: "top level", args: []string{"ap"}, wantErr: "unknown command ap, did you mean app?"},
+		{name: "no suggestion", args: []string{"app", "xyzzy"}, wantErr: "unknown command xyzzy for command app"},
+		{name: "args after double dash", args: []string{"app", "--", "sevre"}},
+		{name: "leaf takes args", args: []string{"app", "serve", "status", "sevre"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := strict.Parse(cmds, tt.args)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Parse(%v) returned error %v", tt.args, err)
+				}
+				return
+			}
+			if err == nil || !strings.HasSuffix(err.Error(), tt.wantErr) {
+				t.Errorf("Parse(%v) error = %v, want %q", tt.args, err, tt.wantErr)
+			}
+		})
+	}
+	if _, err := Parse(cmds, []string{"app", "sevre"}); err != nil {
+		t.Errorf("Parse without strict mode returned error %v", err)
+	}
+
+	// help serve shows the same help as serve --help
+	pcs, err := strict.Parse(cmds, []string{"app", "help", "srv"})
+	if err != nil {
+		t.Fatalf("Parse returned error %v", err)
+	}
+	var got strings.Builder
+	if err := pcs.Invoked().Command().Handler()(context.Background(), pcs, testEnv{&got}); err != nil {
+		t.Fatalf("help handler returned error %v", err)
+	}
+	want := "Usage: prog app srv [options] [command] [args...]\n\nrun the server\n\nCommands:\n  status  show status\n\nOptions:\n  -h, --help      show help\n"
+	if got.String() != want {
+		t.Errorf("help srv =\n%s\nwant\n%s", got.String(), want)
+	}
+	pcs, _ = strict.Parse(cmds, []string{"app", "help", "sevre"})
+	if err := pcs.Invoked().Command().Handler()(context.Background(), pcs, testEnv{&got}); err == nil {
+		t.Errorf("help for an unknown command did not return an error")
+	}
+}
+
+// testEnv is a command.Env that only has an output stream
+type testEnv struct {
+	out io.Writer
+}
+
+func (e testEnv) Stdin() io.Reader          { return strings.NewReader("") }
+func (e testEnv) Stdout() io.Writer         { return e.out }
+func (e testEnv) Stderr() io.Writer         { return io.Discard }
+func (e testEnv) Getenv(name string) string { return "" }
+func (e testEnv) Getwd() (string, error)    { return "/", nil }
+func (e testEnv) OnShutdown(ShutdownHook)   {}
@@ -1,17 +1,28 @@
 package command
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/SpencerBrown/go-http/config"
 	"github.com/SpencerBrown/go-http/option"
+	"github.com/SpencerBrown/go-http/util"
 )
 
 // ParsedCommands represents the parsed command line arguments and the parsed commands themselves with options
 // args is the command line arguments
 type ParsedCommands struct {
-	commands []ParsedCommand // parsed commands in order
-	args     []string        // command line arguments
+	commands   []ParsedCommand // parsed commands in order
+	args       []string        // command line arguments
+	root       Commands        // the top level of the command tree that was parsed
+	progName   string          // name of the program, for help output
+	help       bool            // true if --help or -h was given
+	parsedArgs []ParsedArg     // args converted according to the invoked command's argument specifications
 }
 
 // ParsedCommand represents a parsed command with its options as specified and defaulted
@@ -21,12 +32,48 @@ type ParsedCommand struct {
 	name        string               // actual command name, not an alias
 	invokedName string               // the name or alias used to invoke this command
 	options     option.ParsedOptions // actual options for this command, not aliases or short names
+	cmd         *Command             // the command that was invoked
+	available   option.Options       // options that can be given for this command, its own and the inherited persistent options
+	persistent  option.Options       // persistent options of this command and its ancestors, inherited by subcommands
+}
+
+// Parser holds the settings for parsing a command line.
+// The zero Parser parses the command line only.
+//
+// If ConfigOption is set, it names a string option holding the path of a configuration file (see package config).
+// The sections of the file are command paths from the top of the tree, such as [app.serve],
+// and the keys are option names or aliases. Keys outside any section apply to the deepest invoked command that has the option.
+// A key that no invoked command has is an error, whether it is in the section of an invoked command or outside any section,
+// and so are a section that is not a command path and the ConfigOption option itself.
+// A missing file is ignored unless the path was given explicitly.
+type Parser struct {
+	GetEnvVar    func(string) string          // A function to get an environment variable, nil if the environment is not used; an empty value counts as unset
+	LookupEnvVar func(string) (string, bool)  // Like os.LookupEnv, used instead of GetEnvVar if not nil, so that a variable set to "" sets its option to ""
+	EnvPrefix    string                       // If not empty, every option can also be set by the environment variable PREFIX_NAME
+	ConfigOption string                       // Name of the option that holds the configuration file path, "" if there is none
+	GetWorkDir   func() (string, error)       // A function to get the working directory, for a relative configuration file path
+	ReadFile     func(string) ([]byte, error) // A function to read the configuration file, os.ReadFile if nil
+	Strict       bool                         // If true, an unknown command where subcommands are possible is an error instead of the start of the args
+	ProgName     string                       // Name of the program, for help output
+	Partial      bool                         // If true, the command line is incomplete, as during shell completion, and required options are not checked
+}
+
+// Parse parses the raw args with a zero Parser, so only the command line is used.
+func Parse(cmds Commands, cmdArgs []string) (*ParsedCommands, error) {
+	return (&Parser{}).Parse(cmds, cmdArgs)
 }
 
 // Parse parses the raw args and sets the options and args accordingly
 // Parse identifies the subcommands being used and returns a ParseCommands struct with the command line arguments and consolidated options.
-// --help automatically prints out the options and parent commands for that subcommand branch of the tree.
-func Parse(cmds Commands, cmdArgs []string) (*ParsedCommands, error) {
+// Options not given on the command line are then taken from the environment, if there is a LookupEnvVar or GetEnvVar function,
+// then from the configuration file, if there is a ConfigOption, or else they keep their default values.
+// --help or -h, unless the command has its own option with that name, stops parsing and marks the ParsedCommands
+// so that the caller can print the help for the commands invoked so far, see WriteHelp.
+// Once all the sources are applied, any required options that were not given are reported together in one error,
+// and then the option groups of the invoked commands are checked.
+// If the invoked command specifies its positional arguments, the args are checked and converted, see Command.AddArg.
+// An error in the use of the commands, options or arguments is a *ParseError, see errors.go for what it can hold.
+func (p *Parser) Parse(cmds Commands, cmdArgs []string) (*ParsedCommands, error) {
 	if cmds == nil || len(cmds) == 0 {
 		return nil, fmt.Errorf("command.Parse called with nil or empty Commands")
 	}
@@ -34,79 +81,575 @@ func Parse(cmds Commands, cmdArgs []string) (*ParsedCommands, error) {
 	parsedCmds := ParsedCommands{
 		commands: make([]ParsedCommand, 0),
 		args:     make([]string, 0),
+		root:     cmds,
+		progName: p.ProgName,
+	}
+	if err := Validate(cmds); err != nil {
+		return nil, err
 	}
-	// nextCommand tracks where we are in the list of commands and parsed commands
-	nextCommand := 0
+	// current is the parsed command whose options we are parsing, nil until we have seen a command
+	var current *ParsedCommand
+	// level is the set of commands that the next command must come from, the subcommands of currentCmd
+	level := cmds
 	// iArg is the index of cmdArgs where we are at the moment, when we exit the loop it will point to the first arg after options and commands
 	iArg := 0
 	// We loop through the command line arguments, parsing commands and options
 	// note that command names are case insensitive
 	for ; iArg < len(cmdArgs); iArg++ {
-		cmdArg := strings.ToLower(strings.TrimSpace(cmdArgs[iArg]))	
+		cmdArg := strings.TrimSpace(cmdArgs[iArg])
 		if cmdArg == "--" {
 			// stop parsing flags when you see a bare "--", the rest is args
 			iArg++
 			break
 		}
-		if cmdArg == "-" {
-			// stop parsing flags when you see a bare "-" it and following are args
+		if cmdArg == "-" || strings.HasPrefix(cmdArg, "---") {
+			// stop parsing flags when you see a bare "-" or a triple dash, it and following are args
 			break
 		}
+		if isHelp(current, cmdArg) {
+			// the rest of the command line is ignored, the environment and configuration are not needed for help
+			parsedCmds.help = true
+			return &parsedCmds, nil
+		}
 		if strings.HasPrefix(cmdArg, "--") {
-			// find the option with the double dash prefix
 			// note we have already checked for a bare "--" so we know there's more in the arg string
-			// it could be --option=value or --option value or just --option for a boolean flag
-			// so we need to parse it accordingly
-			optName, optValue, hasEquals := strings.Cut(cmdArg[2:], "=")
-			if !hasEquals {
-				// it's of the form --option value or just --option
-				// see if it's a boolean option
-				if iArg < len(cmdArgs)-1 { // make sure there's another arg for the value
-					iArg++
-					optValue = cmdArgs[iArg]
-				}
+			if current == nil {
+				return nil, parsedCmds.usageError(&UnknownOptionError{Token: cmdArg, ArgIndex: iArg})
+			}
+			var err error
+			iArg, err = parseLongOption(current, cmdArgs, iArg)
+			if err != nil {
+				return nil, parsedCmds.usageError(err)
 			}
-			// TODO: process optName and optValue
 			continue
 		}
-		// check if the arg is a command at the current point in the command tree
-		foundCmd := false
-		if nextCommand < len(cmds) {
-			if cmds[nextCommand].name == cmdArg {
-				foundCmd = true
-				nextCommand++
+		if strings.HasPrefix(cmdArg, "-") {
+			// note we have already checked for a bare "-" so we know there's at least one short option
+			if current == nil {
+				return nil, parsedCmds.usageError(&UnknownOptionError{Token: cmdArg, ArgIndex: iArg})
+			}
+			var err error
+			iArg, err = parseShortOption(current, cmdArgs, iArg)
+			if err != nil {
+				return nil, parsedCmds.usageError(err)
 			}
+			continue
 		}
-		if !foundCmd {
+		// check if the arg is a command at the current point in the command tree
+		cmd := GetCommandByName(level, cmdArg)
+		if cmd == nil {
+			if p.Strict && len(level) > 0 {
+				return nil, parsedCmds.usageError(unknownCommandError(current, level, cmdArg, iArg))
+			}
 			// stop parsing flags and subcommands when you see a non-flag non-command argument
 			break
 		}
+		// descend into the subcommands of the command we found
+		level = cmd.Subcommands()
+		parsedCmds.commands = append(parsedCmds.commands, newParsedCommand(cmd, cmdArg, current))
+		current = &parsedCmds.commands[len(parsedCmds.commands)-1]
 	}
 	// set the remaining args
+	argsIndex := iArg
 	if iArg < len(cmdArgs) {
 		parsedCmds.args = cmdArgs[iArg:]
 	}
+	if err := p.applyEnv(&parsedCmds); err != nil {
+		return nil, err
+	}
+	if err := p.applyConfig(&parsedCmds); err != nil {
+		return nil, err
+	}
+	if !p.Partial {
+		if current != nil && len(current.cmd.args) > 0 {
+			parsedArgs, err := parseArgs(current.cmd, parsedCmds.args, argsIndex)
+			if err != nil {
+				return nil, parsedCmds.usageError(err)
+			}
+			parsedCmds.parsedArgs = parsedArgs
+		}
+		if err := checkRequired(&parsedCmds); err != nil {
+			return nil, parsedCmds.usageError(err)
+		}
+		if err := checkGroups(&parsedCmds); err != nil {
+			return nil, parsedCmds.usageError(err)
+		}
+	}
 	return &parsedCmds, nil
 }
 
+// checkRequired returns an error listing every required option of the invoked commands that was not given,
+// each with the path of the command that has it. A persistent option is listed once, for the command that declared it.
+func checkRequired(parsedCmds *ParsedCommands) error {
+	missing := make([]MissingOption, 0)
+	path := make([]string, 0)
+	for _, pc := range parsedCmds.commands {
+		path = append(path, pc.name)
+		own := option.NewOptions()
+		own = append(own, pc.cmd.options...)
+		own = append(own, pc.cmd.persistentOptions...)
+		for _, opt := range own {
+			if opt.IsRequired() && !pc.options[opt.Name()].IsSet() {
+				missing = append(missing, MissingOption{Path: slices.Clone(path), Option: opt})
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return &MissingOptionsError{Missing: missing}
+}
+
+// applyEnv sets the options that were not given on the command line from environment variables.
+// The option's own environment variables are tried in order, then the one derived from EnvPrefix.
+func (p *Parser) applyEnv(parsedCmds *ParsedCommands) error {
+	lookup := p.LookupEnvVar
+	if lookup == nil {
+		if p.GetEnvVar == nil {
+			return nil
+		}
+		lookup = func(name string) (string, bool) {
+			value := p.GetEnvVar(name)
+			return value, value != ""
+		}
+	}
+	for _, pc := range parsedCmds.commands {
+		for _, po := range pc.options {
+			if po.IsSet() {
+				// set on the command line, or already set from the environment as a shared persistent option
+				continue
+			}
+			envVars := po.Option().EnvVars()
+			if p.EnvPrefix != "" {
+				envVars = append(envVars[:len(envVars):len(envVars)], envVarName(p.EnvPrefix, po.Name()))
+			}
+			for _, envVar := range envVars {
+				if value, ok := lookup(envVar); ok {
+					if err := po.SetEnvValue(envVar, value); err != nil {
+						return parsedCmds.usageError(err)
+					}
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// applyConfig sets the options that were not given on the command line or in the environment
+// from the configuration file named by the ConfigOption option.
+func (p *Parser) applyConfig(parsedCmds *ParsedCommands) error {
+	if p.ConfigOption == "" {
+		return nil
+	}
+	cfgOpt := parsedCmds.LookupOption(p.ConfigOption)
+	if cfgOpt == nil {
+		return nil
+	}
+	path, ok := cfgOpt.GetParsedValueAny().(string)
+	if !ok {
+		return fmt.Errorf("command.Parse: configuration file option %s must be a string", p.ConfigOption)
+	}
+	if path == "" {
+		return nil
+	}
+	if !filepath.IsAbs(path) && p.GetWorkDir != nil {
+		workDir, err := p.GetWorkDir()
+		if err != nil {
+			return fmt.Errorf("command.Parse: cannot get working directory for configuration file: %w", err)
+		}
+		path = filepath.Join(workDir, path)
+	}
+	readFile := p.ReadFile
+	if readFile == nil {
+		readFile = os.ReadFile
+	}
+	data, err := readFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !cfgOpt.IsSet() {
+			return nil // the default configuration file need not exist
+		}
+		return fmt.Errorf("command.Parse: %w", err)
+	}
+	cfg, err := config.Parse(path, data)
+	if err != nil {
+		return fmt.Errorf("command.Parse: %w", err)
+	}
+	// sections of commands that were not invoked are skipped, but each must be a command path
+	for _, e := range cfg.Entries {
+		if len(e.Section) > 0 && !isCommandPath(parsedCmds.root, e.Section) {
+			origin := fmt.Sprintf("%s:%d", cfg.Path, e.Line)
+			return parsedCmds.usageError(&UnknownSectionError{Section: e.Section, Origin: origin})
+		}
+	}
+	apply := func(po *option.ParsedOption, e config.Entry) error {
+		if po.Name() == p.ConfigOption {
+			origin := fmt.Sprintf("%s:%d", cfg.Path, e.Line)
+			return parsedCmds.usageError(&ConfigOptionError{Key: e.Key, Origin: origin})
+		}
+		if err := setConfigValue(po, cfg.Path, e); err != nil {
+			return parsedCmds.usageError(err)
+		}
+		return nil
+	}
+	// keys outside any section, applied to the deepest command that has the option
+	for _, e := range cfg.Section(nil) {
+		var po *option.ParsedOption
+		for i := len(parsedCmds.commands) - 1; i >= 0 && po == nil; i-- {
+			po = parsedCmds.commands[i].availableParsedOption(e.Key)
+		}
+		if po == nil {
+			origin := fmt.Sprintf("%s:%d", cfg.Path, e.Line)
+			invoked := parsedCmds.commands[len(parsedCmds.commands)-1].name
+			return parsedCmds.usageError(&UnknownOptionError{Command: invoked, Token: e.Key, ArgIndex: -1, Origin: origin})
+		}
+		if err := apply(po, e); err != nil {
+			return err
+		}
+	}
+	// sections for each command in the invoked path
+	section := make([]string, 0)
+	for i := range parsedCmds.commands {
+		pc := &parsedCmds.commands[i]
+		section = append(section, pc.name)
+		for _, e := range cfg.Section(section) {
+			po := pc.availableParsedOption(e.Key)
+			if po == nil {
+				origin := fmt.Sprintf("%s:%d", cfg.Path, e.Line)
+				return parsedCmds.usageError(&UnknownOptionError{Command: pc.name, Token: e.Key, ArgIndex: -1, Origin: origin})
+			}
+			if err := apply(po, e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isCommandPath returns true if path is the names of commands from the top of the tree cmds down
+func isCommandPath(cmds Commands, path []string) bool {
+	for _, name := range path {
+		i := slices.IndexFunc(cmds, func(cmd *Command) bool { return cmd.name == name })
+		if i < 0 {
+			return false
+		}
+		cmds = cmds[i].subcommands
+	}
+	return true
+}
+
+// setConfigValue sets a parsed option from a configuration file entry,
+// unless it was already set on the command line or in the environment.
+// A later entry for the same option overrides an earlier one.
+func setConfigValue(po *option.ParsedOption, path string, e config.Entry) error {
+	if po.Source() > option.SourceConfig {
+		return nil
+	}
+	return po.SetConfigValue(path, e.Line, e.Value)
+}
+
+// checkGroups checks the option groups of each invoked command
+func checkGroups(parsedCmds *ParsedCommands) error {
+	path := make([]string, 0)
+	for _, pc := range parsedCmds.commands {
+		path = append(path, pc.name)
+		for _, g := range pc.cmd.groups {
+			if err := g.Check(pc.options); err != nil {
+				var ce *option.ConstraintError
+				if !errors.As(err, &ce) {
+					return err
+				}
+				return &GroupError{Path: slices.Clone(path), Err: ce}
+			}
+		}
+	}
+	return nil
+}
+
+// LookupOption finds a parsed option by name or alias in the invoked commands, deepest first, or nil if none
+func (pcs *ParsedCommands) LookupOption(name string) *option.ParsedOption {
+	for i := len(pcs.commands) - 1; i >= 0; i-- {
+		if po := pcs.commands[i].availableParsedOption(name); po != nil {
+			return po
+		}
+	}
+	return nil
+}
+
+// availableParsedOption finds a parsed option by name or alias among the options available to the command, or nil if none
+func (pc *ParsedCommand) availableParsedOption(name string) *option.ParsedOption {
+	opt := option.GetOptionByName(pc.available, name)
+	if opt == nil {
+		return nil
+	}
+	return pc.options[opt.Name()]
+}
+
+// envVarName derives the environment variable name for an option from a prefix,
+// for example prefix "myapp" and option "log-level" give MYAPP_LOG_LEVEL
+func envVarName(prefix string, name string) string {
+	return strings.ToUpper(strings.ReplaceAll(prefix+"_"+name, "-", "_"))
+}
+
+// unknownCommandError returns the error for an unknown command in strict mode,
+// suggesting the closest names and aliases at this level of the tree
+func unknownCommandError(current *ParsedCommand, level Commands, cmdArg string, argIndex int) error {
+	e := &UnknownCommandError{Name: cmdArg, ArgIndex: argIndex, Suggestions: suggestCommands(level, cmdArg)}
+	if current != nil {
+		e.Parent = current.name
+	}
+	return e
+}
+
+// usageError wraps an error in a ParseError with the path of the commands invoked so far
+func (pcs *ParsedCommands) usageError(err error) error {
+	path := make([]string, 0, len(pcs.commands))
+	for _, pc := range pcs.commands {
+		path = append(path, pc.name)
+	}
+	return &ParseError{Path: path, Err: err}
+}
+
+// suggestCommands returns the names and aliases in cmds that are close to name: within an edit distance of 2,
+// or starting with name. The closest come first.
+func suggestCommands(cmds Commands, name string) []string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	type suggestion struct {
+		name     string
+		distance int
+	}
+	suggestions := make([]suggestion, 0)
+	for _, cmd := range cmds {
+		for _, candidate := range append([]string{cmd.name}, cmd.alias...) {
+			distance := util.EditDistance(name, candidate)
+			if distance <= 2 || (name != "" && strings.HasPrefix(candidate, name)) {
+				suggestions = append(suggestions, suggestion{candidate, distance})
+			}
+		}
+	}
+	slices.SortFunc(suggestions, func(a, b suggestion) int {
+		if a.distance != b.distance {
+			return a.distance - b.distance
+		}
+		return strings.Compare(a.name, b.name)
+	})
+	names := make([]string, 0, len(suggestions))
+	for _, s := range suggestions {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+// isHelp returns true if cmdArg asks for help: --help or -h, unless the current command has its own option by that name
+func isHelp(current *ParsedCommand, cmdArg string) bool {
+	switch {
+	case strings.EqualFold(cmdArg, "--help"):
+		return current == nil || option.GetOptionByName(current.available, "help") == nil
+	case cmdArg == "-h":
+		return current == nil || option.GetOptionByShortName(current.available, 'h') == nil
+	}
+	return false
+}
+
+// newParsedCommand creates a ParsedCommand for a Command invoked by invokedName,
+// with every option of the command set to its default value.
+// parent is the parsed command this is a subcommand of, or nil for a top level command.
+// The persistent options of the parent and its ancestors are shared with the parent,
+// so a value set at any level is seen at every level below the command that owns the option.
+func newParsedCommand(cmd *Command, invokedName string, parent *ParsedCommand) ParsedCommand {
+	pc := ParsedCommand{
+		name:        cmd.name,
+		invokedName: strings.ToLower(strings.TrimSpace(invokedName)),
+		options:     option.NewParsedOptions(),
+		cmd:         cmd,
+		available:   option.NewOptions(),
+		persistent:  option.NewOptions(),
+	}
+	if parent != nil {
+		for _, opt := range parent.persistent {
+			pc.options[opt.Name()] = parent.options[opt.Name()]
+			pc.persistent = append(pc.persistent, opt)
+		}
+	}
+	for _, opt := range cmd.persistentOptions {
+		pc.options[opt.Name()] = option.NewParsedOption(opt)
+		pc.persistent = append(pc.persistent, opt)
+	}
+	for _, opt := range cmd.options {
+		pc.options[opt.Name()] = option.NewParsedOption(opt)
+	}
+	pc.available = append(pc.available, cmd.options...)
+	pc.available = append(pc.available, pc.persistent...)
+	return pc
+}
+
+// parseLongOption handles a long option at cmdArgs[iArg], which starts with a double dash.
+// We allow these cases:
+//
+//	--option=value (value cannot be empty)
+//	--option value (not allowed if option is boolean)
+//	--option (if it is boolean, sets it to true)
+//
+// It returns the index of the last arg consumed.
+func parseLongOption(pc *ParsedCommand, cmdArgs []string, iArg int) (int, error) {
+	optIndex := iArg
+	optName, optValue, hasEquals := strings.Cut(strings.TrimSpace(cmdArgs[iArg])[2:], "=")
+	invokedName := strings.ToLower(strings.TrimSpace(optName))
+	opt := option.GetOptionByName(pc.available, invokedName)
+	if opt == nil {
+		return iArg, &UnknownOptionError{Command: pc.name, Token: "--" + invokedName, ArgIndex: iArg}
+	}
+	switch {
+	case hasEquals:
+		if optValue == "" {
+			return iArg, &MissingValueError{Option: opt, Token: "--" + invokedName, ArgIndex: iArg, Empty: true}
+		}
+	case opt.IsBool():
+		optValue = "true"
+	default:
+		// the next arg must be a value, even if there is a default
+		if iArg+1 >= len(cmdArgs) {
+			return iArg, &MissingValueError{Option: opt, Token: "--" + invokedName, ArgIndex: iArg}
+		}
+		iArg++
+		optValue = cmdArgs[iArg]
+	}
+	return iArg, pc.options[opt.Name()].SetValue(invokedName, optIndex, optValue)
+}
+
+// parseShortOption handles one or more single-rune options at cmdArgs[iArg], which starts with a single dash.
+// We allow these cases:
+//
+//	-o (only if option is boolean, sets it to true)
+//	-o=value
+//	-ovalue (not allowed if option is boolean)
+//	-o value (not allowed if option is boolean)
+//	-abo any of the above for "o", only if a and b are boolean
+//
+// Only the last rune in a cluster can take a value; a non-boolean option ends the cluster.
+// Short names are case sensitive. It returns the index of the last arg consumed.
+func parseShortOption(pc *ParsedCommand, cmdArgs []string, iArg int) (int, error) {
+	optIndex := iArg
+	shortOpts := []rune(strings.TrimSpace(cmdArgs[iArg])[1:])
+	for i, shortName := range shortOpts {
+		opt := option.GetOptionByShortName(pc.available, shortName)
+		if opt == nil {
+			return iArg, &UnknownOptionError{Command: pc.name, Token: "-" + string(shortName), ArgIndex: iArg}
+		}
+		invokedName := string(shortName)
+		rest := string(shortOpts[i+1:])
+		var optValue string
+		switch {
+		case strings.HasPrefix(rest, "="):
+			// -o=value, for any type of option
+			optValue = rest[1:]
+			if optValue == "" {
+				return iArg, &MissingValueError{Option: opt, Token: "-" + string(shortName), ArgIndex: iArg, Empty: true}
+			}
+		case opt.IsBool():
+			// -o, possibly followed by more options in the cluster
+			if err := pc.options[opt.Name()].SetValue(invokedName, optIndex, "true"); err != nil {
+				return iArg, err
+			}
+			continue
+		case rest != "":
+			// -ovalue
+			optValue = rest
+		default:
+			// -o value, the next arg must be a value even if there is a default
+			if iArg+1 >= len(cmdArgs) {
+				return iArg, &MissingValueError{Option: opt, Token: "-" + string(shortName), ArgIndex: iArg}
+			}
+			iArg++
+			optValue = cmdArgs[iArg]
+		}
+		// a value ends the cluster
+		return iArg, pc.options[opt.Name()].SetValue(invokedName, optIndex, optValue)
+	}
+	return iArg, nil
+}
+
+// String returns a string representation of the ParsedCommands
+func (pcs *ParsedCommands) String() string {
+	if pcs == nil {
+		return "ParsedCommands: nil\n"
+	}
+	var builder strings.Builder
+	builder.WriteString("ParsedCommands:\n")
+	for i := range pcs.commands {
+		builder.WriteString(util.Indent(pcs.commands[i].String(), 1))
+	}
+	builder.WriteString(fmt.Sprintf("Args: %s\n", strings.Join(pcs.args, " ")))
+	return builder.String()
+}
+
+// Commands returns the parsed commands in the order they were invoked
+func (pcs *ParsedCommands) Commands() []ParsedCommand {
+	return pcs.commands
+}
+
+// Invoked returns the last (deepest) parsed command, which is the one whose handler is called.
+// It returns nil if no command was found on the command line.
+func (pcs *ParsedCommands) Invoked() *ParsedCommand {
+	if len(pcs.commands) == 0 {
+		return nil
+	}
+	return &pcs.commands[len(pcs.commands)-1]
+}
+
+// HelpRequested returns true if --help or -h was given on the command line
+func (pcs *ParsedCommands) HelpRequested() bool {
+	return pcs.help
+}
+
+// ProgName returns the name of the program given to the Parser, for help output
+func (pcs *ParsedCommands) ProgName() string {
+	return pcs.progName
+}
+
+// Root returns the top level of the command tree that was parsed
+func (pcs *ParsedCommands) Root() Commands {
+	return pcs.root
+}
+
+// Args returns the command line arguments remaining after the commands and options
+func (pcs *ParsedCommands) Args() []string {
+	return pcs.args
+}
+
 // String returns a string representation of the ParsedCommand
 func (pc *ParsedCommand) String() string {
 	if pc == nil {
 		return "ParsedCommand: nil\n"
 	}
 	var builder strings.Builder
-	builder.WriteString("ParsedCommand:\n")
-	builder.WriteString(pc.commands.String())
-	builder.WriteString(fmt.Sprintf("Args: %s\n", strings.Join(pc.args, " ")))
+	builder.WriteString(fmt.Sprintf("ParsedCommand: %s (invoked as %s)\n", pc.name, pc.invokedName))
+	builder.WriteString(util.Indent(pc.options.String(), 1))
 	return builder.String()
 }
 
-// return the commands in the ParsedCommand
-func (pc *ParsedCommand) Commands() Commands {
-	return pc.commands
+// Name returns the actual name of the parsed command, not an alias
+func (pc *ParsedCommand) Name() string {
+	return pc.name
+}
+
+// InvokedName returns the name or alias used to invoke the command
+func (pc *ParsedCommand) InvokedName() string {
+	return pc.invokedName
+}
+
+// Options returns the parsed options for the command
+func (pc *ParsedCommand) Options() option.ParsedOptions {
+	return pc.options
+}
+
+// AvailableOptions returns the options that can be given for the command: its own options and its persistent options,
+// followed by the persistent options it inherits
+func (pc *ParsedCommand) AvailableOptions() option.Options {
+	return pc.available
 }
 
-// return the args in the ParsedCommand
-func (pc *ParsedCommand) Args() []string {
-	return pc.args
+// Command returns the Command that was invoked
+func (pc *ParsedCommand) Command() *Command {
+	return pc.cmd
 }
@@ -0,0 +1,531 @@
+package command
+
+import (
+	"errors"
+	"io/fs"
+	"slices"
+	"testing"
+
+	"github.com/SpencerBrown/go-http/option"
+)
+
+// testCommands builds a small command tree used by the parse tests
+func testCommands() Commands {
+	opts := option.NewOptions()
+	opts.AddOptionMust(option.NewOptionMust("name", []string{"nm"}, 'n', nil, "name", "", true, "anon", nil))
+	opts.AddOptionMust(option.NewOptionMust("count", nil, 'c', nil, "count", "", true, 1, nil))
+	opts.AddOptionMust(option.NewOptionMust("verbose", nil, 'v', nil, "verbose", "", false, false, nil))
+	opts.AddOptionMust(option.NewOptionMust("extra", nil, 'x', nil, "extra", "", false, false, nil))
+	cmds := NewCommands()
+	cmds.AddCommandMust(NewCommandMust("root", []string{"rt"}, "root", "", opts))
+	return cmds
+}
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantArgs    []string
+		wantValues  map[string]any
+		wantInvoked map[string]string
+		wantDefault map[string]bool
+		wantErr     bool
+	}{
+		{
+			name:        "defaults only",
+			args:        []string{"root"},
+			wantArgs:    []string{},
+			wantValues:  map[string]any{"name": "anon", "count": 1, "verbose": false},
+			wantDefault: map[string]bool{"name": true, "count": true, "verbose": false},
+		},
+		{
+			name:        "equals, separate value and bool",
+			args:        []string{"root", "--name=joe", "--count", "3", "--verbose", "arg1", "arg2"},
+			wantArgs:    []string{"arg1", "arg2"},
+			wantValues:  map[string]any{"name": "joe", "count": 3, "verbose": true},
+			wantInvoked: map[string]string{"name": "name", "count": "count", "verbose": "verbose"},
+			wantDefault: map[string]bool{"name": false, "count": false},
+		},
+		{
+			name:        "alias and case folding",
+			args:        []string{"ROOT", "--NM=Joe", "--verbose=false"},
+			wantArgs:    []string{},
+			wantValues:  map[string]any{"name": "Joe", "verbose": false},
+			wantInvoked: map[string]string{"name": "nm", "verbose": "verbose"},
+		},
+		{
+			name:       "double dash ends options",
+			args:       []string{"root", "--", "--count", "2"},
+			wantArgs:   []string{"--count", "2"},
+			wantValues: map[string]any{"count": 1},
+		},
+		{
+			name:       "single dash is an arg",
+			args:       []string{"root", "-", "--count=2"},
+			wantArgs:   []string{"-", "--count=2"},
+			wantValues: map[string]any{"count": 1},
+		},
+		{
+			name:        "short boolean cluster",
+			args:        []string{"root", "-vx", "arg"},
+			wantArgs:    []string{"arg"},
+			wantValues:  map[string]any{"verbose": true, "extra": true},
+			wantInvoked: map[string]string{"verbose": "v", "extra": "x"},
+		},
+		{
+			name:        "short cluster with trailing separate value",
+			args:        []string{"root", "-vxc", "5"},
+			wantArgs:    []string{},
+			wantValues:  map[string]any{"verbose": true, "extra": true, "count": 5},
+			wantInvoked: map[string]string{"count": "c"},
+		},
+		{
+			name:       "short cluster with attached value",
+			args:       []string{"root", "-vc42", "-nfred"},
+			wantArgs:   []string{},
+			wantValues: map[string]any{"verbose": true, "count": 42, "name": "fred"},
+		},
+		{
+			name:       "short with equals",
+			args:       []string{"root", "-c=7", "-v=false", "-n", "-x"},
+			wantArgs:   []string{},
+			wantValues: map[string]any{"count": 7, "verbose": false, "name": "-x", "extra": false},
+		},
+		{
+			name:    "short unknown in cluster",
+			args:    []string{"root", "-vq"},
+			wantErr: true,
+		},
+		{
+			name:    "short missing value",
+			args:    []string{"root", "-vc"},
+			wantErr: true,
+		},
+		{
+			name:    "short case sensitive",
+			args:    []string{"root", "-V"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown option",
+			args:    []string{"root", "--nope"},
+			wantErr: true,
+		},
+		{
+			name:    "missing value",
+			args:    []string{"root", "--count"},
+			wantErr: true,
+		},
+		{
+			name:    "empty value",
+			args:    []string{"root", "--name="},
+			wantErr: true,
+		},
+		{
+			name:    "bad value",
+			args:    []string{"root", "--count=many"},
+			wantErr: true,
+		},
+		{
+			name:    "option before command",
+			args:    []string{"--count=2", "root"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pcs, err := Parse(testCommands(), tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Parse(%v) did not return an error", tt.args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%v) returned error %v", tt.args, err)
+			}
+			if !slices.Equal(pcs.Args(), tt.wantArgs) {
+				t.Errorf("Parse(%v) args = %v, want %v", tt.args, pcs.Args(), tt.wantArgs)
+			}
+			if len(pcs.Commands()) != 1 || pcs.Commands()[0].Name() != "root" {
+				t.Fatalf("Parse(%v) commands = %v, want root", tt.args, pcs.Commands())
+			}
+			opts := pcs.Commands()[0].Options()
+			for name, want := range tt.wantValues {
+				if got := opts.GetParsedOption(name).GetParsedValueAny(); got != want {
+					t.Errorf("Parse(%v) option %s = %v, want %v", tt.args, name, got, want)
+				}
+			}
+			for name, want := range tt.wantInvoked {
+				if got := opts.GetParsedOption(name).InvokedName(); got != want {
+					t.Errorf("Parse(%v) option %s invoked as %q, want %q", tt.args, name, got, want)
+				}
+			}
+			for name, want := range tt.wantDefault {
+				if got := opts.GetParsedOption(name).IsDefault(); got != want {
+					t.Errorf("Parse(%v) option %s isDefault = %v, want %v", tt.args, name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSubcommands(t *testing.T) {
+	subOpts := option.NewOptions()
+	subOpts.AddOptionMust(option.NewOptionMust("port", nil, 'p', nil, "port", "", true, 8080, nil))
+	root := NewCommandMust("app", nil, "app", "", nil)
+	serve := NewCommandMust("serve", []string{"srv", "s"}, "serve", "", subOpts)
+	serve.AddSubcommandMust(NewCommandMust("status", []string{"st"}, "status", "", nil))
+	root.AddSubcommandMust(serve)
+	root.AddSubcommandMust(NewCommandMust("version", nil, "version", "", nil))
+	cmds := NewCommands()
+	cmds.AddCommandMust(root)
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantNames   []string
+		wantInvoked []string
+		wantArgs    []string
+	}{
+		{
+			name:        "root only",
+			args:        []string{"app", "x"},
+			wantNames:   []string{"app"},
+			wantInvoked: []string{"app"},
+			wantArgs:    []string{"x"},
+		},
+		{
+			name:        "alias with options between levels",
+			args:        []string{"app", "SRV", "-p", "9000", "st", "a", "b"},
+			wantNames:   []string{"app", "serve", "status"},
+			wantInvoked: []string{"app", "srv", "st"},
+			wantArgs:    []string{"a", "b"},
+		},
+		{
+			name:        "sibling is not a subcommand",
+			args:        []string{"app", "version", "serve"},
+			wantNames:   []string{"app", "version"},
+			wantInvoked: []string{"app", "version"},
+			wantArgs:    []string{"serve"},
+		},
+		{
+			name:        "unknown command starts args",
+			args:        []string{"nope", "serve"},
+			wantNames:   []string{},
+			wantInvoked: []string{},
+			wantArgs:    []string{"nope", "serve"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pcs, err := Parse(cmds, tt.args)
+			if err != nil {
+				t.Fatalf("Parse(%v) returned error %v", tt.args, err)
+			}
+			gotNames := make([]string, 0)
+			gotInvoked := make([]string, 0)
+			for _, pc := range pcs.Commands() {
+				gotNames = append(gotNames, pc.Name())
+				gotInvoked = append(gotInvoked, pc.InvokedName())
+			}
+			if !slices.Equal(gotNames, tt.wantNames) {
+				t.Errorf("Parse(%v) names = %v, want %v", tt.args, gotNames, tt.wantNames)
+			}
+			if !slices.Equal(gotInvoked, tt.wantInvoked) {
+				t.Errorf("Parse(%v) invoked names = %v, want %v", tt.args, gotInvoked, tt.wantInvoked)
+			}
+			if !slices.Equal(pcs.Args(), tt.wantArgs) {
+				t.Errorf("Parse(%v) args = %v, want %v", tt.args, pcs.Args(), tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestParsePersistentOptions(t *testing.T) {
+	root := NewCommandMust("app", nil, "app", "", nil)
+	root.AddPersistentOptionMust(option.NewOptionMust("verbose", nil, 'v', nil, "verbose", "", true, false, nil))
+	root.AddPersistentOptionMust(option.NewOptionMust("config", nil, 0, nil, "config file", "", true, "app.conf", nil))
+	serve := NewCommandMust("serve", nil, "serve", "", nil)
+	status := NewCommandMust("status", nil, "status", "", nil)
+	serve.AddSubcommandMust(status)
+	root.AddSubcommandMust(serve)
+	cmds := NewCommands()
+	cmds.AddCommandMust(root)
+
+	pcs, err := Parse(cmds, []string{"app", "serve", "status", "-v", "--config=x.conf"})
+	if err != nil {
+		t.Fatalf("Parse returned error %v", err)
+	}
+	for _, pc := range pcs.Commands() {
+		if got := pc.Options().GetParsedOption("verbose").GetParsedValueAny(); got != true {
+			t.Errorf("command %s verbose = %v, want true", pc.Name(), got)
+		}
+		if got := pc.Options().GetParsedOption("config").GetParsedValueAny(); got != "x.conf" {
+			t.Errorf("command %s config = %v, want x.conf", pc.Name(), got)
+		}
+	}
+
+	// an option on a subcommand that conflicts with an inherited persistent option
+	opts := option.NewOptions()
+	opts.AddOptionMust(option.NewOptionMust("vv", nil, 'v', nil, "conflict", "", false, false, nil))
+	status.AddSubcommandMust(NewCommandMust("deep", nil, "deep", "", opts))
+	if _, err := Parse(cmds, []string{"app"}); err == nil {
+		t.Errorf("Parse did not report a conflict with a persistent option")
+	}
+}
+
+func TestParseEnvironment(t *testing.T) {
+	opts := option.NewOptions()
+	port := option.NewOptionMust("port", nil, 'p', nil, "port", "", true, 8080, nil)
+	port.SetEnvVars("PORT", "HTTP_PORT")
+	opts.AddOptionMust(port)
+	opts.AddOptionMust(option.NewOptionMust("log-level", nil, 0, nil, "log level", "", true, "info", nil))
+	opts.AddOptionMust(option.NewOptionMust("name", nil, 0, nil, "name", "", true, "anon", nil))
+	cmds := NewCommands()
+	cmds.AddCommandMust(NewCommandMust("serve", nil, "serve", "", opts))
+	env := map[string]string{"HTTP_PORT": "9000", "APP_LOG_LEVEL": "debug", "APP_NAME": "env"}
+	parser := Parser{GetEnvVar: func(name string) string { return env[name] }, EnvPrefix: "app"}
+
+	pcs, err := parser.Parse(cmds, []string{"serve", "--name=cli"})
+	if err != nil {
+		t.Fatalf("Parse returned error %v", err)
+	}
+	opts2 := pcs.Invoked().Options()
+	tests := []struct {
+		name       string
+		wantValue  any
+		wantEnvVar string
+	}{
+		{name: "port", wantValue: 9000, wantEnvVar: "HTTP_PORT"},
+		{name: "log-level", wantValue: "debug", wantEnvVar: "APP_LOG_LEVEL"},
+		{name: "name", wantValue: "cli", wantEnvVar: ""},
+	}
+	for _, tt := range tests {
+		po := opts2.GetParsedOption(tt.name)
+		if po.GetParsedValueAny() != tt.wantValue || po.EnvVar() != tt.wantEnvVar {
+			t.Errorf("option %s = %v from %q, want %v from %q", tt.name, po.GetParsedValueAny(), po.EnvVar(), tt.wantValue, tt.wantEnvVar)
+		}
+	}
+
+	env["PORT"] = "not a number"
+	if _, err := parser.Parse(cmds, []string{"serve"}); err == nil {
+		t.Errorf("Parse did not return an error for an invalid environment value")
+	}
+
+	// with GetEnvVar an empty variable is unset, with LookupEnvVar it sets the option to ""
+	delete(env, "PORT")
+	env["APP_NAME"] = ""
+	pcs, err = parser.Parse(cmds, []string{"serve"})
+	if err != nil {
+		t.Fatalf("Parse returned error %v", err)
+	}
+	if got := pcs.Invoked().Options().GetParsedOption("name").GetParsedValueAny(); got != "anon" {
+		t.Errorf("with GetEnvVar, option name = %q, want the default", got)
+	}
+	parser.LookupEnvVar = func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	pcs, err = parser.Parse(cmds, []string{"serve"})
+	if err != nil {
+		t.Fatalf("Parse returned error %v", err)
+	}
+	po := pcs.Invoked().Options().GetParsedOption("name")
+	if po.GetParsedValueAny() != "" || po.EnvVar() != "APP_NAME" {
+		t.Errorf("with LookupEnvVar, option name = %q from %q, want \"\" from APP_NAME", po.GetParsedValueAny(), po.EnvVar())
+	}
+}
+
+func TestParseConfigFile(t *testing.T) {
+	root := NewCommandMust("app", nil, "app", "", nil)
+	root.AddPersistentOptionMust(option.NewOptionMust("config", nil, 'c', nil, "config file", "", true, "app.conf", nil))
+	root.AddPersistentOptionMust(option.NewOptionMust("verbose", nil, 'v', nil, "verbose", "", true, false, nil))
+	opts := option.NewOptions()
+	opts.AddOptionMust(option.NewOptionMust("port", nil, 'p', nil, "port", "", true, 8080, nil))
+	opts.AddOptionMust(option.NewOptionMust("host", nil, 0, nil, "host", "", true, "localhost", nil))
+	opts.AddOptionMust(option.NewOptionMust("name", nil, 0, nil, "name", "", true, "anon", nil))
+	root.AddSubcommandMust(NewCommandMust("serve", nil, "serve", "", opts))
+	root.AddSubcommandMust(NewCommandMust("status", nil, "status", "", nil))
+	cmds := NewCommands()
+	cmds.AddCommandMust(root)
+	files := map[string]string{
+		"/work/app.conf":   "verbose = true\n[app.serve]\nport = 9000\nhost = example.com\nport = 9001\n",
+		"/etc/other.json":  `{"app": {"serve": {"bogus": 1}}}`,
+		"/work/bad.conf":   "[app.serve]\nport = lots\n",
+		"/work/empty.conf": "",
+		"/work/top.conf":   "bogus = 1\n[app.serve]\nport = 9000\n",
+		"/work/typo.conf":  "[app.serv]\nport = 9000\n",
+		"/work/short.conf": "[serve]\nport = 9000\n",
+		"/work/other.conf": "[app.status]\nport = 9000\n",
+		"/work/self.conf":  "[app]\nconfig = other.conf\n",
+	}
+	parser := Parser{
+		GetEnvVar:    func(name string) string { return map[string]string{"APP_HOST": "env.com"}[name] },
+		EnvPrefix:    "app",
+		ConfigOption: "config",
+		GetWorkDir:   func() (string, error) { return "/work", nil },
+		ReadFile: func(path string) ([]byte, error) {
+			data, ok := files[path]
+			if !ok {
+				return nil, fs.ErrNotExist
+			}
+			return []byte(data), nil
+		},
+	}
+
+	pcs, err := parser.Parse(cmds, []string{"app", "serve", "--name=cli"})
+	if err != nil {
+		t.Fatalf("Parse returned error %v", err)
+	}
+	opts2 := pcs.Invoked().Options()
+	want := map[string]any{"verbose": true, "port": 9001, "host": "env.com", "name": "cli"}
+	for name, value := range want {
+		if got := opts2.GetParsedOption(name).GetParsedValueAny(); got != value {
+			t.Errorf("option %s = %v, want %v", name, got, value)
+		}
+	}
+	if path, line := opts2.GetParsedOption("port").ConfigFile(); path != "/work/app.conf" || line != 5 {
+		t.Errorf("port came from %s:%d, want /work/app.conf:5", path, line)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{name: "unknown option in section", args: []string{"app", "-c", "/etc/other.json", "serve"}, wantErr: true},
+		{name: "unknown option outside sections", args: []string{"app", "--config=top.conf", "serve"}, wantErr: true},
+		{name: "unknown section", args: []string{"app", "--config=typo.conf", "serve"}, wantErr: true},
+		{name: "section without the top command", args: []string{"app", "--config=short.conf", "serve"}, wantErr: true},
+		{name: "section of a command not invoked", args: []string{"app", "--config=other.conf", "serve"}},
+		{name: "configuration file option", args: []string{"app", "--config=self.conf", "serve"}, wantErr: true},
+		{name: "invalid value", args: []string{"app", "--config=bad.conf", "serve"}, wantErr: true},
+		{name: "explicit missing file", args: []string{"app", "--config=missing.conf", "serve"}, wantErr: true},
+		{name: "empty file", args: []string{"app", "--config=empty.conf", "serve"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.Parse(cmds, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse(%v) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			}
+		})
+	}
+	var sectionErr *UnknownSectionError
+	if _, err := parser.Parse(cmds, []string{"app", "--config=typo.conf", "serve"}); !errors.As(err, &sectionErr) || sectionErr.Origin != "/work/typo.conf:2" {
+		t.Errorf("Parse returned %v, want an UnknownSectionError at /work/typo.conf:2", err)
+	}
+	var cfgErr *ConfigOptionError
+	if _, err := parser.Parse(cmds, []string{"app", "--config=self.conf", "serve"}); !errors.As(err, &cfgErr) || cfgErr.Key != "config" {
+		t.Errorf("Parse returned %v, want a ConfigOptionError for config", err)
+	}
+	delete(files, "/work/app.conf")
+	if _, err := parser.Parse(cmds, []string{"app", "serve"}); err != nil {
+		t.Errorf("Parse returned error %v for a missing default configuration file", err)
+	}
+}
+
+func TestParseRequired(t *testing.T) {
+	root := NewCommandMust("app", nil, "the app", "", nil)
+	token := option.NewOptionMust("token", nil, 0, nil, "API token", "", false, "", nil)
+	token.SetRequired(true)
+	token.SetEnvVars("TOKEN")
+	root.AddPersistentOptionMust(token)
+	opts := option.NewOptions()
+	port := option.NewOptionMust("port", nil, 'p', nil, "port", "", true, 8080, nil)
+	port.SetRequired(true)
+	opts.AddOptionMust(port)
+	serve := NewCommandMust("serve", nil, "serve", "", opts)
+	serve.AddSubcommandMust(NewCommandMust("status", nil, "status", "", nil))
+	root.AddSubcommandMust(serve)
+	cmds := NewCommands()
+	cmds.AddCommandMust(root)
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		partial bool
+		wantErr string
+	}{
+		{name: "all given", args: []string{"app", "serve", "-p", "1", "--token=x"}},
+		{name: "from environment", args: []string{"app", "serve", "-p", "1"}, env: map[string]string{"TOKEN": "x"}},
+		{name: "default does not count", args: []string{"app", "--token", "x", "serve"},
+			wantErr: "command.Parse: missing required options: --port for command app serve"},
+		{name: "all missing", args: []string{"app", "serve", "status"},
+			wantErr: "command.Parse: missing required options: --token for command app, --port for command app serve"},
+		{name: "help", args: []string{"app", "serve", "--help"}},
+		{name: "partial", args: []string{"app", "serve"}, partial: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := Parser{GetEnvVar: func(name string) string { return tt.env[name] }, Partial: tt.partial}
+			_, err := parser.Parse(cmds, tt.args)
+			gotErr := ""
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if gotErr != tt.wantErr {
+				t.Errorf("Parse(%v) error = %q, want %q", tt.args, gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseOptionGroups(t *testing.T) {
+	opts := option.NewOptions()
+	opts.AddOptionMust(option.NewOptionMust("file", nil, 'f', nil, "input file", "", false, "", nil))
+	opts.AddOptionMust(option.NewOptionMust("url", []string{"link"}, 0, nil, "input URL", "", false, "", nil))
+	opts.AddOptionMust(option.NewOptionMust("cert", nil, 'c', nil, "certificate", "", false, "", nil))
+	opts.AddOptionMust(option.NewOptionMust("key", nil, 0, nil, "private key", "", true, "key.pem", nil))
+	opts.AddOptionMust(option.NewOptionMust("quiet", nil, 'q', nil, "less output", "", false, false, nil))
+	opts.AddOptionMust(option.NewOptionMust("verbose", nil, 'v', nil, "more output", "", false, false, nil))
+	load := NewCommandMust("load", nil, "load", "", opts)
+	load.AddOptionGroupMust(option.ExactlyOne, "file", "link")
+	load.AddOptionGroupMust(option.RequiredTogether, "cert", "key")
+	load.AddOptionGroupMust(option.MutuallyExclusive, "quiet", "verbose")
+	cmds := NewCommands()
+	cmds.AddCommandMust(load)
+	env := map[string]string{}
+	parser := Parser{GetEnvVar: func(name string) string { return env[name] }, EnvPrefix: "app"}
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "valid", args: []string{"load", "-f", "a", "--cert=c.pem", "--key=k.pem", "-q"}},
+		{name: "neither", args: []string{"load"},
+			wantErr: "command.Parse: command load: one of options --file or --url is required"},
+		{name: "both", args: []string{"load", "-f", "a", "--link", "b"},
+			wantErr: "command.Parse: command load: options -f and --link cannot be used together"},
+		{name: "both from environment", args: []string{"load", "-f", "a"}, env: map[string]string{"APP_URL": "b"},
+			wantErr: "command.Parse: command load: options -f and --url (from environment variable APP_URL) cannot be used together"},
+		{name: "default does not count", args: []string{"load", "-f", "a", "-c", "c.pem"},
+			wantErr: "command.Parse: command load: -c given without --key"},
+		{name: "exclusive", args: []string{"load", "-f", "a", "-qv"},
+			wantErr: "command.Parse: command load: options -q and -v cannot be used together"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env = tt.env
+			_, err := parser.Parse(cmds, tt.args)
+			gotErr := ""
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if gotErr != tt.wantErr {
+				t.Errorf("Parse(%v) error = %q, want %q", tt.args, gotErr, tt.wantErr)
+			}
+		})
+	}
+
+	if err := load.AddOptionGroup(option.AtLeastOne, "file", "bogus"); err == nil {
+		t.Errorf("AddOptionGroup with an unknown option returned no error")
+	}
+	if err := load.AddOptionGroup(option.AtLeastOne, "url", "link"); err == nil {
+		t.Errorf("AddOptionGroup with an option and its alias returned no error")
+	}
+}
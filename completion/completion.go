@@ -0,0 +1,287 @@
+// Package completion generates shell completion scripts for bash, zsh and fish from a command tree.
+//
+// The scripts do not list the commands and options themselves. Instead they call the program back
+// with the hidden first argument __complete followed by the words typed so far,
+// and the program writes one candidate per line, as the value and a description separated by a tab.
+// run.Runner handles __complete by calling Write, so the completions always match the command tree.
+//
+// Options and commands can offer their own values and args for completion, see option.Completer and command.ArgsCompleter.
+package completion
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/SpencerBrown/go-http/command"
+	"github.com/SpencerBrown/go-http/option"
+)
+
+// EntryPoint is the hidden first argument that the completion scripts use to call back into the program.
+const EntryPoint = "__complete"
+
+// Candidate is a possible completion of the word being typed, with a description that some shells show.
+type Candidate = option.Completion
+
+// Complete returns the candidates for completing the last of words, which is the word being typed, possibly "".
+// The words before it are parsed against cmds to find the command being completed.
+// Candidates are the subcommands and their aliases, or the options if the word starts with a dash,
+// in order of their values, followed by any from the command's ArgsCompleter.
+// The value of an option, after the option or after --option=, is completed by the option's Completer,
+// and the args after a command's options by its ArgsCompleter.
+func Complete(cmds command.Commands, words []string) []Candidate {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+	before := words[:len(words)-1]
+	if candidates, ok := completeOptionValue(cmds, before, current); ok {
+		return candidates
+	}
+	pcs, err := parsePartial(cmds, before)
+	if err != nil {
+		return nil
+	}
+	invoked := pcs.Invoked()
+	if len(pcs.Args()) > 0 || slices.Contains(before, "--") {
+		// completing args
+		return completeArgs(invoked, pcs, current)
+	}
+	var candidates []Candidate
+	switch {
+	case strings.HasPrefix(current, "-"):
+		if invoked == nil {
+			return nil
+		}
+		candidates = optionCandidates(invoked.AvailableOptions(), current)
+	case invoked == nil:
+		candidates = commandCandidates(cmds, current)
+	default:
+		candidates = commandCandidates(invoked.Command().Subcommands(), current)
+	}
+	slices.SortFunc(candidates, func(a, b Candidate) int { return strings.Compare(a.Value, b.Value) })
+	if !strings.HasPrefix(current, "-") {
+		candidates = append(candidates, completeArgs(invoked, pcs, current)...)
+	}
+	return candidates
+}
+
+// parsePartial parses the words before the one being typed, which need not be a complete command line
+func parsePartial(cmds command.Commands, words []string) (*command.ParsedCommands, error) {
+	parser := command.Parser{Partial: true}
+	return parser.Parse(cmds, words)
+}
+
+// completeArgs returns the candidates from the ArgsCompleter of the invoked command, if there is one
+func completeArgs(invoked *command.ParsedCommand, pcs *command.ParsedCommands, current string) []Candidate {
+	if invoked == nil || invoked.Command().ArgsCompleter() == nil {
+		return nil
+	}
+	return invoked.Command().ArgsCompleter()(pcs, current)
+}
+
+// completeOptionValue returns the candidates for completing the value of an option, and true,
+// if the word being typed is --option=value or follows an option that takes a value.
+// Only an option with a Completer has candidates.
+func completeOptionValue(cmds command.Commands, before []string, current string) ([]Candidate, bool) {
+	// --option=value
+	if name, prefix, found := strings.Cut(current, "="); found && strings.HasPrefix(name, "--") {
+		pcs, err := parsePartial(cmds, before)
+		if err != nil || pcs.Invoked() == nil {
+			return nil, true
+		}
+		opt := option.GetOptionByName(pcs.Invoked().AvailableOptions(), name[2:])
+		if opt == nil || opt.Completer() == nil {
+			return nil, true
+		}
+		candidates := make([]Candidate, 0)
+		for _, c := range opt.Completer()(pcs, prefix) {
+			candidates = append(candidates, Candidate{Value: name + "=" + c.Value, Description: c.Description})
+		}
+		return candidates, true
+	}
+	// --option value or -o value, where the previous word is the option
+	if len(before) == 0 {
+		return nil, false
+	}
+	previous := before[len(before)-1]
+	if !strings.HasPrefix(previous, "-") || previous == "-" || previous == "--" || strings.Contains(previous, "=") {
+		return nil, false
+	}
+	pcs, err := parsePartial(cmds, before[:len(before)-1])
+	if err != nil || pcs.Invoked() == nil || len(pcs.Args()) > 0 {
+		return nil, false
+	}
+	opts := pcs.Invoked().AvailableOptions()
+	var opt *option.Option
+	if strings.HasPrefix(previous, "--") {
+		opt = option.GetOptionByName(opts, previous[2:])
+	} else {
+		// in a cluster of short options, only the last can take a value, and only if it is not followed by the value
+		shortNames := []rune(previous[1:])
+		opt = option.GetOptionByShortName(opts, shortNames[len(shortNames)-1])
+		for _, r := range shortNames[:len(shortNames)-1] {
+			if o := option.GetOptionByShortName(opts, r); o == nil || !o.IsBool() {
+				return nil, false
+			}
+		}
+	}
+	if opt == nil || opt.IsBool() {
+		return nil, false
+	}
+	if opt.Completer() == nil {
+		return nil, true
+	}
+	return opt.Completer()(pcs, current), true
+}
+
+// commandCandidates returns the names and aliases of the commands that start with prefix, case insensitively
+func commandCandidates(cmds command.Commands, prefix string) []Candidate {
+	prefix = strings.ToLower(prefix)
+	candidates := make([]Candidate, 0)
+	for _, cmd := range cmds {
+		for _, name := range append([]string{cmd.Name()}, cmd.Alias()...) {
+			if strings.HasPrefix(name, prefix) {
+				candidates = append(candidates, Candidate{Value: name, Description: cmd.Description()})
+			}
+		}
+	}
+	return candidates
+}
+
+// optionCandidates returns the options that match the word being typed.
+// A lone dash matches all the short and long names, a double dash prefix matches the long names and aliases.
+// Nothing is offered within a cluster of short options.
+func optionCandidates(opts option.Options, current string) []Candidate {
+	candidates := make([]Candidate, 0)
+	add := func(value string, description string) {
+		if strings.HasPrefix(value, current) {
+			candidates = append(candidates, Candidate{Value: value, Description: description})
+		}
+	}
+	if current != "-" && !strings.HasPrefix(current, "--") {
+		return candidates
+	}
+	for _, opt := range opts {
+		if current == "-" && opt.ShortName() != 0 {
+			add("-"+string(opt.ShortName()), opt.Description())
+			for _, r := range opt.ShortNameAliases() {
+				add("-"+string(r), opt.Description())
+			}
+		}
+		for _, name := range append([]string{opt.Name()}, opt.Alias()...) {
+			add("--"+name, opt.Description())
+		}
+	}
+	if option.GetOptionByName(opts, "help") == nil {
+		add("--help", "show help")
+	}
+	return candidates
+}
+
+// Write writes the candidates for completing words to w, one per line, as the value and description separated by a tab.
+func Write(w io.Writer, cmds command.Commands, words []string) error {
+	for _, c := range Complete(cmds, words) {
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", c.Value, c.Description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bash writes a bash completion script for the program progName to w.
+// Bash splits --option=value into the words --option, = and value, and completes only the text after the =,
+// so the script joins the words back together for the program and removes what comes before the text from the candidates.
+func Bash(w io.Writer, progName string) error {
+	_, err := fmt.Fprintf(w, `# bash completion for %[1]s
+_%[2]s_complete() {
+    local -a words=()
+    local i word n
+    for ((i = 1; i <= COMP_CWORD; i++)); do
+        word="${COMP_WORDS[i]}"
+        n=${#words[@]}
+        if ((n > 0)) && [[ "${word}" == "=" || "${words[n-1]}" == *= ]]; then
+            words[n-1]+="${word}"
+        else
+            words+=("${word}")
+        fi
+    done
+    local cur="${words[${#words[@]}-1]}"
+    word="${COMP_WORDS[COMP_CWORD]}"
+    [[ "${word}" == "=" ]] && word=""
+    local prefix="${cur%%"${word}"}"
+    local IFS=$'\n'
+    local candidates
+    candidates=$("${COMP_WORDS[0]}" %[3]s "${words[@]}" 2>/dev/null | cut -f1)
+    COMPREPLY=($(compgen -W "${candidates}" -- "${cur}"))
+    COMPREPLY=("${COMPREPLY[@]#"${prefix}"}")
+}
+complete -o default -F _%[2]s_complete %[1]s
+`, progName, funcName(progName), EntryPoint)
+	return err
+}
+
+// Zsh writes a zsh completion script for the program progName to w.
+func Zsh(w io.Writer, progName string) error {
+	_, err := fmt.Fprintf(w, `#compdef %[1]s
+# zsh completion for %[1]s
+_%[2]s() {
+    local -a candidates
+    local line
+    for line in "${(@f)$("${words[1]}" %[3]s "${(@)words[2,CURRENT]}" 2>/dev/null)}"; do
+        [[ -n "${line}" ]] && candidates+=("${${line%%%%$'\t'*}//:/\\:}:${line#*$'\t'}")
+    done
+    _describe '%[1]s' candidates
+}
+compdef _%[2]s %[1]s
+`, progName, funcName(progName), EntryPoint)
+	return err
+}
+
+// Fish writes a fish completion script for the program progName to w.
+func Fish(w io.Writer, progName string) error {
+	_, err := fmt.Fprintf(w, `# fish completion for %[1]s
+function __%[2]s_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    %[1]s %[3]s $tokens[2..-1] 2>/dev/null
+end
+complete -c %[1]s -f -a '(__%[2]s_complete)'
+`, progName, funcName(progName), EntryPoint)
+	return err
+}
+
+// funcName makes a shell function name from a program name by replacing anything but letters, digits and underscores
+func funcName(progName string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, progName)
+}
+
+// NewCommand creates a "completion" command with bash, zsh and fish subcommands
+// that write the completion script for the program to the output stream.
+func NewCommand() *command.Command {
+	cmd := command.NewCommandMust("completion", nil, "generate shell completion scripts",
+		"Completion writes a script that sets up completion of this program's commands and options for a shell.", nil)
+	shells := []struct {
+		name  string
+		write func(io.Writer, string) error
+		how   string
+	}{
+		{"bash", Bash, "To enable completion, add this line to ~/.bashrc: source <(<program> completion bash)"},
+		{"zsh", Zsh, "To enable completion, save the script as _<program> in a directory in $fpath."},
+		{"fish", Fish, "To enable completion, save the script as ~/.config/fish/completions/<program>.fish"},
+	}
+	for _, shell := range shells {
+		sub := command.NewCommandMust(shell.name, nil, "generate the "+shell.name+" completion script", shell.how, nil)
+		sub.SetHandler(func(ctx context.Context, pc *command.ParsedCommands, env command.Env) error {
+			return shell.write(env.Stdout(), pc.ProgName())
+		})
+		cmd.AddSubcommandMust(sub)
+	}
+	return cmd
+}
@@ -0,0 +1,159 @@
+package completion
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SpencerBrown/go-http/command"
+	"github.com/SpencerBrown/go-http/option"
+)
+
+func testCommands() command.Commands {
+	root := command.NewCommandMust("app", nil, "the app", "", nil)
+	root.AddPersistentOptionMust(option.NewOptionMust("verbose", nil, 'v', nil, "more output", "", false, false, nil))
+	opts := option.NewOptions()
+	opts.AddOptionMust(option.NewOptionMust("port", []string{"listen"}, 'p', nil, "port", "", true, 8080, nil))
+	serve := command.NewCommandMust("serve", []string{"srv"}, "run the server", "", opts)
+	serve.AddSubcommandMust(command.NewCommandMust("status", nil, "show status", "", nil))
+	root.AddSubcommandMust(serve)
+	root.AddSubcommandMust(command.NewCommandMust("version", nil, "show version", "", nil))
+	cmds := command.NewCommands()
+	cmds.AddCommandMust(root)
+	return cmds
+}
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+		want  []string
+	}{
+		{name: "top level", words: []string{""}, want: []string{"app"}},
+		{name: "subcommands", words: []string{"app", ""}, want: []string{"serve", "srv", "version"}},
+		{name: "subcommand prefix", words: []string{"app", "S"}, want: []string{"serve", "srv"}},
+		{name: "long options with inherited", words: []string{"app", "serve", "--"}, want: []string{"--help", "--listen", "--port", "--verbose"}},
+		{name: "long option prefix", words: []string{"app", "srv", "-v", "--p"}, want: []string{"--port"}},
+		{name: "all options", words: []string{"app", "serve", "-"}, want: []string{"--help", "--listen", "--port", "--verbose", "-p", "-v"}},
+		{name: "after option value", words: []string{"app", "serve", "-p", "80", "st"}, want: []string{"status"}},
+		{name: "args", words: []string{"app", "version", "x", ""}, want: []string{}},
+		{name: "after double dash", words: []string{"app", "--", ""}, want: []string{}},
+		{name: "bad option before", words: []string{"app", "--bogus", ""}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, c := range Complete(testCommands(), tt.words) {
+				got = append(got, c.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Complete(%q) = %v, want %v", tt.words, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScripts(t *testing.T) {
+	scripts := map[string]func(*strings.Builder) error{
+		"bash": func(b *strings.Builder) error { return Bash(b, "my-app") },
+		"zsh":  func(b *strings.Builder) error { return Zsh(b, "my-app") },
+		"fish": func(b *strings.Builder) error { return Fish(b, "my-app") },
+	}
+	for shell, write := range scripts {
+		var b strings.Builder
+		if err := write(&b); err != nil {
+			t.Fatalf("%s script returned error %v", shell, err)
+		}
+		for _, want := range []string{"my-app", "_my_app", EntryPoint} {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("%s script does not contain %q:\n%s", shell, want, b.String())
+			}
+		}
+	}
+}
+
+func TestBashScript(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not found")
+	}
+	var script strings.Builder
+	if err := Bash(&script, "my-app"); err != nil {
+		t.Fatalf("Bash returned error %v", err)
+	}
+	// my-app stands in for the program: it offers the words it was given as a candidate, then two option values
+	script.WriteString(`my-app() { shift; local IFS=' '; printf '%s\t\n' "$*" --profile=prod --profile=dev; }
+COMP_WORDS=("$@")
+COMP_CWORD=$((${#COMP_WORDS[@]} - 1))
+_my_app_complete
+printf '%s\n' "${COMPREPLY[@]}"
+`)
+	tests := []struct {
+		name  string
+		words []string
+		want  string
+	}{
+		{name: "after equals", words: []string{"my-app", "serve", "--profile", "=", "p"}, want: "prod\n"},
+		{name: "right after equals", words: []string{"my-app", "serve", "--profile", "="}, want: "prod\ndev\n"},
+		{name: "words joined", words: []string{"my-app", "--profile", "=", "dev", "serve", ""}, want: "--profile=dev serve \n--profile=prod\n--profile=dev\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := exec.Command(bash, append([]string{"-c", script.String(), "bash"}, tt.words...)...).Output()
+			if err != nil {
+				t.Fatalf("bash returned error %v", err)
+			}
+			if string(out) != tt.want {
+				t.Errorf("completing %q = %q, want %q", tt.words, out, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompleteDynamic(t *testing.T) {
+	cmds := testCommands()
+	app := command.GetCommandByName(cmds, "app")
+	profile := option.NewOptionMust("profile", nil, 'P', nil, "profile to use", "", true, "default", nil)
+	profile.SetCompleter(func(parsed option.ParsedState, prefix string) []option.Completion {
+		candidates := make([]option.Completion, 0)
+		for _, p := range []string{"dev", "prod", "staging"} {
+			if strings.HasPrefix(p, prefix) {
+				candidates = append(candidates, option.Completion{Value: p, Description: "profile " + p})
+			}
+		}
+		return candidates
+	})
+	app.AddPersistentOptionMust(profile)
+	status := command.GetCommandByName(command.GetCommandByName(app.Subcommands(), "serve").Subcommands(), "status")
+	status.SetArgsCompleter(func(pc *command.ParsedCommands, prefix string) []option.Completion {
+		// offer targets depending on the profile parsed so far
+		p := option.GetParsedValueMust[string](pc.LookupOption("profile"))
+		return []option.Completion{{Value: p + "-target", Description: "target"}}
+	})
+
+	tests := []struct {
+		name  string
+		words []string
+		want  []string
+	}{
+		{name: "long option value", words: []string{"app", "--profile", "d"}, want: []string{"dev"}},
+		{name: "long option equals", words: []string{"app", "serve", "--profile=p"}, want: []string{"--profile=prod"}},
+		{name: "short option value in cluster", words: []string{"app", "-vP", ""}, want: []string{"dev", "prod", "staging"}},
+		{name: "value without completer", words: []string{"app", "serve", "--port", ""}, want: []string{}},
+		{name: "args use parsed options", words: []string{"app", "-P", "prod", "serve", "status", ""}, want: []string{"prod-target"}},
+		{name: "args after args", words: []string{"app", "serve", "status", "x", ""}, want: []string{"default-target"}},
+		{name: "bool option takes no value", words: []string{"app", "-v", "se"}, want: []string{"serve"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, c := range Complete(cmds, tt.words) {
+				got = append(got, c.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Complete(%q) = %v, want %v", tt.words, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+// Package config reads configuration files whose sections map onto a command tree.
+//
+// Two formats are supported. A JSON file is an object whose keys are option names,
+// and whose nested objects are sections for subcommands:
+//
+//	{"verbose": true, "serve": {"port": 8080, "hosts": ["a.com", "b.com"]}}
+//
+// An INI file, in a simple TOML-like style, has sections named by the command path with dots:
+//
+//	verbose = true
+//	[serve]
+//	port = 8080
+//	hosts = ["a.com", "b.com"]
+//
+// In both formats a list of values is converted to a comma-separated string, as expected by []string options.
+// In an INI file, lines starting with # or ; are comments, and a value can be a quoted Go string.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Entry is a single key and value from a configuration file.
+type Entry struct {
+	Section []string // command path of the section, lower case, empty for keys outside any section
+	Key     string   // option name, lower case
+	Value   string   // value as a string, to be parsed according to the type of the option
+	Line    int      // line number in the file where the key appears
+}
+
+// Config is the entries of a configuration file, in the order they appear.
+type Config struct {
+	Path    string  // path of the file
+	Entries []Entry // entries in file order
+}
+
+// ReadFile reads and parses a configuration file, using Parse.
+func ReadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config.ReadFile: %w", err)
+	}
+	return Parse(path, data)
+}
+
+// Parse parses the contents of a configuration file.
+// A file whose name ends in .json is parsed as JSON, any other file as INI.
+func Parse(path string, data []byte) (*Config, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return ParseJSON(path, data)
+	}
+	return ParseINI(path, data)
+}
+
+// Section returns the entries in a section, in file order.
+// Pass an empty section to get the entries outside any section.
+func (cfg *Config) Section(section []string) []Entry {
+	entries := make([]Entry, 0)
+	for _, e := range cfg.Entries {
+		if slices.Equal(e.Section, section) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// ParseINI parses a configuration file in INI format.
+func ParseINI(path string, data []byte) (*Config, error) {
+	cfg := &Config{Path: path, Entries: make([]Entry, 0)}
+	section := make([]string, 0)
+	for i, raw := range strings.Split(string(data), "\n") {
+		lineNum := i + 1
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("config.ParseINI: %s:%d: section name must end with ]", path, lineNum)
+			}
+			section = make([]string, 0)
+			for _, part := range strings.Split(line[1:len(line)-1], ".") {
+				name := strings.ToLower(strings.TrimSpace(part))
+				if name == "" {
+					return nil, fmt.Errorf("config.ParseINI: %s:%d: blank command name in section %s", path, lineNum, line)
+				}
+				section = append(section, name)
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("config.ParseINI: %s:%d: expected key = value", path, lineNum)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			return nil, fmt.Errorf("config.ParseINI: %s:%d: blank key", path, lineNum)
+		}
+		value, err := parseINIValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("config.ParseINI: %s:%d: %w", path, lineNum, err)
+		}
+		cfg.Entries = append(cfg.Entries, Entry{Section: section, Key: key, Value: value, Line: lineNum})
+	}
+	return cfg, nil
+}
+
+// parseINIValue converts a value in an INI file to a string: a quoted string is unquoted,
+// and a list in square brackets becomes a comma-separated string.
+func parseINIValue(value string) (string, error) {
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		items := make([]string, 0)
+		for _, item := range strings.Split(value[1:len(value)-1], ",") {
+			item, err := parseINIValue(strings.TrimSpace(item))
+			if err != nil {
+				return "", err
+			}
+			if item != "" {
+				items = append(items, item)
+			}
+		}
+		return strings.Join(items, ","), nil
+	}
+	if strings.HasPrefix(value, `"`) {
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted value %s", value)
+		}
+		return unquoted, nil
+	}
+	return value, nil
+}
+
+// ParseJSON parses a configuration file in JSON format.
+func ParseJSON(path string, data []byte) (*Config, error) {
+	cfg := &Config{Path: path, Entries: make([]Entry, 0)}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("config.ParseJSON: %s: %w", path, err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("config.ParseJSON: %s: must contain a JSON object", path)
+	}
+	if err := cfg.parseJSONObject(dec, data, make([]string, 0)); err != nil {
+		return nil, fmt.Errorf("config.ParseJSON: %s: %w", path, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("config.ParseJSON: %s: unexpected data after the JSON object", path)
+	}
+	return cfg, nil
+}
+
+// parseJSONObject adds the entries of a JSON object to cfg, after its opening brace has been read.
+// Nested objects are sections for subcommands.
+func (cfg *Config) parseJSONObject(dec *json.Decoder, data []byte, section []string) error {
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := strings.ToLower(strings.TrimSpace(tok.(string))) // object keys are always strings
+		line := lineAt(data, dec.InputOffset())
+		tok, err = dec.Token()
+		if err != nil {
+			return err
+		}
+		if delim, ok := tok.(json.Delim); ok && delim == '{' {
+			subsection := append(section[:len(section):len(section)], key)
+			if err := cfg.parseJSONObject(dec, data, subsection); err != nil {
+				return err
+			}
+			continue
+		}
+		value, isNull, err := jsonValue(dec, tok)
+		if err != nil {
+			return fmt.Errorf("line %d: key %s: %w", line, key, err)
+		}
+		if !isNull {
+			cfg.Entries = append(cfg.Entries, Entry{Section: section, Key: key, Value: value, Line: line})
+		}
+	}
+	_, err := dec.Token() // the closing brace
+	return err
+}
+
+// jsonValue converts a scalar or an array of scalars to a string, given its first token.
+// An array becomes a comma-separated string. It returns true if the value is null.
+func jsonValue(dec *json.Decoder, tok json.Token) (string, bool, error) {
+	switch v := tok.(type) {
+	case nil:
+		return "", true, nil
+	case string:
+		return v, false, nil
+	case json.Number:
+		return v.String(), false, nil
+	case bool:
+		return strconv.FormatBool(v), false, nil
+	case json.Delim:
+		if v != '[' {
+			return "", false, fmt.Errorf("unexpected %s", v)
+		}
+		items := make([]string, 0)
+		for dec.More() {
+			itemTok, err := dec.Token()
+			if err != nil {
+				return "", false, err
+			}
+			if _, ok := itemTok.(json.Delim); ok {
+				return "", false, fmt.Errorf("list items must be strings, numbers or booleans")
+			}
+			item, isNull, err := jsonValue(dec, itemTok)
+			if err != nil {
+				return "", false, err
+			}
+			if !isNull {
+				items = append(items, item)
+			}
+		}
+		if _, err := dec.Token(); err != nil { // the closing bracket
+			return "", false, err
+		}
+		return strings.Join(items, ","), false, nil
+	}
+	return "", false, fmt.Errorf("unexpected value %v", tok)
+}
+
+// lineAt returns the line number of a byte offset in data
+func lineAt(data []byte, offset int64) int {
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
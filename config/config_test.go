@@ -0,0 +1,91 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		data    string
+		want    []Entry
+		wantErr bool
+	}{
+		{
+			name: "ini",
+			path: "app.conf",
+			data: "# comment\nverbose = true\n\n[Serve]\nport = 8080\nname = \"a # b\"\n; comment\n[serve.status]\nhosts = [a.com, \"b.com\"]\n",
+			want: []Entry{
+				{Section: []string{}, Key: "verbose", Value: "true", Line: 2},
+				{Section: []string{"serve"}, Key: "port", Value: "8080", Line: 5},
+				{Section: []string{"serve"}, Key: "name", Value: "a # b", Line: 6},
+				{Section: []string{"serve", "status"}, Key: "hosts", Value: "a.com,b.com", Line: 9},
+			},
+		},
+		{
+			name:    "ini missing equals",
+			path:    "app.ini",
+			data:    "[serve]\nport 8080\n",
+			wantErr: true,
+		},
+		{
+			name:    "ini bad section",
+			path:    "app.ini",
+			data:    "[serve..x]\n",
+			wantErr: true,
+		},
+		{
+			name: "json",
+			path: "app.JSON",
+			data: "{\n  \"verbose\": true,\n  \"serve\": {\n    \"port\": 8080,\n    \"hosts\": [\"a.com\", \"b.com\"],\n    \"skip\": null\n  }\n}\n",
+			want: []Entry{
+				{Section: []string{}, Key: "verbose", Value: "true", Line: 2},
+				{Section: []string{"serve"}, Key: "port", Value: "8080", Line: 4},
+				{Section: []string{"serve"}, Key: "hosts", Value: "a.com,b.com", Line: 5},
+			},
+		},
+		{
+			name:    "json not an object",
+			path:    "app.json",
+			data:    "[1, 2]",
+			wantErr: true,
+		},
+		{
+			name:    "json trailing data",
+			path:    "app.json",
+			data:    `{"port": 8080} {"port": 9090}`,
+			wantErr: true,
+		},
+		{
+			name: "json trailing space",
+			path: "app.json",
+			data: "{\"port\": 8080}\n\n",
+			want: []Entry{{Section: []string{}, Key: "port", Value: "8080", Line: 1}},
+		},
+		{
+			name:    "json nested list",
+			path:    "app.json",
+			data:    `{"hosts": [["a"]]}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse(tt.path, []byte(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Parse(%s) did not return an error", tt.path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%s) returned error %v", tt.path, err)
+			}
+			if !reflect.DeepEqual(cfg.Entries, tt.want) {
+				t.Errorf("Parse(%s) = %+v, want %+v", tt.path, cfg.Entries, tt.want)
+			}
+		})
+	}
+}
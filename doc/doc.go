@@ -0,0 +1,308 @@
+// Package doc generates reference documentation from a command tree:
+// a roff man page in section 1 and a Markdown page for every command path.
+// The output depends only on the command tree, with commands in the order they were added,
+// so it can be checked in and diffed in review.
+package doc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/SpencerBrown/go-http/command"
+	"github.com/SpencerBrown/go-http/option"
+)
+
+// Page is the documentation of one command path, from the top of the command tree.
+type Page struct {
+	ProgName  string             // name of the program, or "" if none
+	Path      []*command.Command // the commands from the top of the tree to the documented command
+	Inherited option.Options     // persistent options inherited from the commands above the documented command
+}
+
+// Pages walks the command tree and returns a page for every command path, parents before their subcommands.
+func Pages(cmds command.Commands, progName string) []Page {
+	pages := make([]Page, 0)
+	cmds.Walk(func(path []*command.Command) error {
+		inherited := option.NewOptions()
+		for _, parent := range path[:len(path)-1] {
+			inherited = append(inherited, parent.PersistentOptions()...)
+		}
+		pages = append(pages, Page{ProgName: progName, Path: slices.Clone(path), Inherited: inherited})
+		return nil
+	})
+	return pages
+}
+
+// Command returns the documented command, the last in the path
+func (pg Page) Command() *command.Command {
+	return pg.Path[len(pg.Path)-1]
+}
+
+// Names returns the program name, if any, followed by the names of the commands in the path
+func (pg Page) Names() []string {
+	names := make([]string, 0)
+	if pg.ProgName != "" {
+		names = append(names, pg.ProgName)
+	}
+	for _, cmd := range pg.Path {
+		names = append(names, cmd.Name())
+	}
+	return names
+}
+
+// Usage returns the usage line of the command
+func (pg Page) Usage() string {
+	usage := append(pg.Names(), "[options]")
+	if len(pg.Command().Subcommands()) > 0 {
+		usage = append(usage, "[command]")
+	}
+	return strings.Join(append(usage, pg.Command().ArgsUsage()), " ")
+}
+
+// Options returns the command's own options followed by its persistent options
+func (pg Page) Options() option.Options {
+	opts := option.NewOptions()
+	opts = append(opts, pg.Command().Options()...)
+	return append(opts, pg.Command().PersistentOptions()...)
+}
+
+// ManName returns the name of the man page, such as prog-app-serve
+func (pg Page) ManName() string {
+	return strings.Join(pg.Names(), "-")
+}
+
+// MarkdownName returns the file name of the Markdown page, such as prog_app_serve.md
+func (pg Page) MarkdownName() string {
+	return strings.Join(pg.Names(), "_") + ".md"
+}
+
+// parent returns the page of the command above this one, and false if this is a top level command
+func (pg Page) parent() (Page, bool) {
+	if len(pg.Path) < 2 {
+		return Page{}, false
+	}
+	parent := pg.Path[len(pg.Path)-2]
+	inherited := pg.Inherited[:len(pg.Inherited)-len(parent.PersistentOptions())]
+	return Page{ProgName: pg.ProgName, Path: pg.Path[:len(pg.Path)-1], Inherited: inherited}, true
+}
+
+// child returns the page of a subcommand of this one
+func (pg Page) child(cmd *command.Command) Page {
+	inherited := append(pg.Inherited[:len(pg.Inherited):len(pg.Inherited)], pg.Command().PersistentOptions()...)
+	return Page{ProgName: pg.ProgName, Path: append(pg.Path[:len(pg.Path):len(pg.Path)], cmd), Inherited: inherited}
+}
+
+// WriteMan writes the page as a roff man page in section 1
+func (pg Page) WriteMan(w io.Writer) error {
+	var b strings.Builder
+	cmd := pg.Command()
+	title := strings.ToUpper(pg.ManName())
+	fmt.Fprintf(&b, ".TH \"%s\" \"1\" \"\" \"\" \"\"\n", roffEscape(title))
+	b.WriteString(".SH NAME\n")
+	fmt.Fprintf(&b, "%s \\- %s\n", roffEscape(pg.ManName()), roffEscape(cmd.Description()))
+	b.WriteString(".SH SYNOPSIS\n")
+	fmt.Fprintf(&b, ".B %s\n", roffEscape(strings.Join(pg.Names(), " ")))
+	fmt.Fprintf(&b, "%s\n", roffEscape(strings.TrimPrefix(pg.Usage(), strings.Join(pg.Names(), " ")+" ")))
+	if cmd.LongDescription() != "" {
+		b.WriteString(".SH DESCRIPTION\n")
+		fmt.Fprintf(&b, "%s\n", roffEscape(cmd.LongDescription()))
+	}
+	if len(cmd.Alias()) > 0 {
+		b.WriteString(".SH ALIASES\n")
+		fmt.Fprintf(&b, "%s\n", roffEscape(strings.Join(cmd.Alias(), ", ")))
+	}
+	if len(cmd.Args()) > 0 {
+		b.WriteString(".SH ARGUMENTS\n")
+		for _, arg := range cmd.Args() {
+			fmt.Fprintf(&b, ".TP\n\\fB%s\\fR \\fI%s\\fR\n%s\n", roffEscape(arg.Usage()), roffEscape(arg.TypeName()),
+				roffEscape(strings.TrimSpace(arg.Description()+" (count "+argCount(arg)+")")))
+		}
+	}
+	writeManOptions(&b, "OPTIONS", pg.Options())
+	writeManOptions(&b, "INHERITED OPTIONS", pg.Inherited)
+	if len(cmd.Subcommands()) > 0 {
+		b.WriteString(".SH COMMANDS\n")
+		for _, sub := range cmd.Subcommands() {
+			names := append([]string{`\fB` + roffEscape(sub.Name()) + `\fR`}, escapeAll(sub.Alias(), roffEscape)...)
+			fmt.Fprintf(&b, ".TP\n%s\n%s\n", strings.Join(names, ", "), roffEscape(sub.Description()))
+		}
+	}
+	seeAlso := make([]string, 0)
+	if parent, ok := pg.parent(); ok {
+		seeAlso = append(seeAlso, `\fB`+roffEscape(parent.ManName())+`\fR(1)`)
+	}
+	for _, sub := range cmd.Subcommands() {
+		seeAlso = append(seeAlso, `\fB`+roffEscape(pg.child(sub).ManName())+`\fR(1)`)
+	}
+	if len(seeAlso) > 0 {
+		b.WriteString(".SH SEE ALSO\n")
+		fmt.Fprintf(&b, "%s\n", strings.Join(seeAlso, ", "))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeManOptions writes a section with a tagged paragraph for each option, if there are any
+func writeManOptions(b *strings.Builder, title string, opts option.Options) {
+	if len(opts) == 0 {
+		return
+	}
+	fmt.Fprintf(b, ".SH %s\n", title)
+	for _, opt := range opts {
+		names := make([]string, 0)
+		for _, name := range opt.CommandLineNames() {
+			names = append(names, `\fB`+roffEscape(name)+`\fR`)
+		}
+		description := opt.Description()
+		if d := opt.DefaultString(); d != "" {
+			description += " (default " + d + ")"
+		}
+		fmt.Fprintf(b, ".TP\n%s \\fI%s\\fR\n%s\n", strings.Join(names, ", "), roffEscape(opt.TypeName()), roffEscape(strings.TrimSpace(description)))
+	}
+}
+
+// argCount describes how many values an argument takes: 1, 0 to 2, 1 or more, or any
+func argCount(arg *command.Arg) string {
+	switch {
+	case arg.Max() == -1 && arg.Min() == 0:
+		return "any"
+	case arg.Max() == -1:
+		return fmt.Sprintf("%d or more", arg.Min())
+	case arg.Min() == arg.Max():
+		return fmt.Sprintf("%d", arg.Min())
+	}
+	return fmt.Sprintf("%d to %d", arg.Min(), arg.Max())
+}
+
+// roffEscape escapes text for roff: backslashes and dashes are escaped,
+// and lines starting with a period or quote are protected from being read as requests
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WriteMarkdown writes the page as Markdown, with links to the pages of the parent command and the subcommands
+func (pg Page) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	cmd := pg.Command()
+	fmt.Fprintf(&b, "# %s\n\n", strings.Join(pg.Names(), " "))
+	if cmd.Description() != "" {
+		fmt.Fprintf(&b, "%s\n\n", cmd.Description())
+	}
+	fmt.Fprintf(&b, "## Synopsis\n\n```\n%s\n```\n\n", pg.Usage())
+	if cmd.LongDescription() != "" {
+		fmt.Fprintf(&b, "%s\n\n", cmd.LongDescription())
+	}
+	if len(cmd.Alias()) > 0 {
+		fmt.Fprintf(&b, "Aliases: %s\n\n", strings.Join(escapeAll(cmd.Alias(), markdownCode), ", "))
+	}
+	if len(cmd.Args()) > 0 {
+		b.WriteString("## Arguments\n\n| Argument | Type | Count | Description |\n| --- | --- | --- | --- |\n")
+		for _, arg := range cmd.Args() {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", markdownCode(arg.Usage()), markdownCell(arg.TypeName()),
+				argCount(arg), markdownCell(arg.Description()))
+		}
+		b.WriteString("\n")
+	}
+	writeMarkdownOptions(&b, "Options", pg.Options())
+	writeMarkdownOptions(&b, "Inherited options", pg.Inherited)
+	if len(cmd.Subcommands()) > 0 {
+		b.WriteString("## Commands\n\n| Command | Aliases | Description |\n| --- | --- | --- |\n")
+		for _, sub := range cmd.Subcommands() {
+			fmt.Fprintf(&b, "| [%s](%s) | %s | %s |\n", sub.Name(), pg.child(sub).MarkdownName(),
+				strings.Join(escapeAll(sub.Alias(), markdownCode), ", "), markdownCell(sub.Description()))
+		}
+		b.WriteString("\n")
+	}
+	if parent, ok := pg.parent(); ok {
+		fmt.Fprintf(&b, "## See also\n\n* [%s](%s) - %s\n", strings.Join(parent.Names(), " "), parent.MarkdownName(), parent.Command().Description())
+	}
+	_, err := io.WriteString(w, strings.TrimSuffix(b.String(), "\n")+"\n")
+	return err
+}
+
+// writeMarkdownOptions writes a section with a table of options, if there are any
+func writeMarkdownOptions(b *strings.Builder, title string, opts option.Options) {
+	if len(opts) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "## %s\n\n| Option | Type | Default | Description |\n| --- | --- | --- | --- |\n", title)
+	for _, opt := range opts {
+		def := ""
+		if opt.HasDefault() {
+			def = markdownCode(opt.DefaultString())
+		}
+		fmt.Fprintf(b, "| %s | %s | %s | %s |\n", strings.Join(escapeAll(opt.CommandLineNames(), markdownCode), ", "),
+			markdownCell(opt.TypeName()), def, markdownCell(opt.Description()))
+	}
+	b.WriteString("\n")
+}
+
+// markdownCode formats s as inline code, "" stays empty
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + markdownCell(s) + "`"
+}
+
+// markdownCell escapes the pipe characters in text for a table cell, and puts it on one line
+func markdownCell(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ")
+}
+
+// escapeAll applies an escape function to each string
+func escapeAll(strs []string, escape func(string) string) []string {
+	escaped := make([]string, 0, len(strs))
+	for _, s := range strs {
+		escaped = append(escaped, escape(s))
+	}
+	return escaped
+}
+
+// GenManTree writes a man page for every command path to the directory dir, named like prog-app-serve.1
+func GenManTree(cmds command.Commands, progName string, dir string) error {
+	for _, pg := range Pages(cmds, progName) {
+		if err := writeFile(filepath.Join(dir, pg.ManName()+".1"), pg.WriteMan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GenMarkdownTree writes a Markdown page for every command path to the directory dir, named like prog_app_serve.md
+func GenMarkdownTree(cmds command.Commands, progName string, dir string) error {
+	for _, pg := range Pages(cmds, progName) {
+		if err := writeFile(filepath.Join(dir, pg.MarkdownName()), pg.WriteMarkdown); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile creates a file and writes it with the write function
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("doc: %w", err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("doc: writing %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("doc: %w", err)
+	}
+	return nil
+}
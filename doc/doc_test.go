@@ -0,0 +1,156 @@
+package doc
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/SpencerBrown/go-http/command"
+	"github.com/SpencerBrown/go-http/option"
+)
+
+// testCommands returns app with a persistent option and a serve subcommand with its own subcommands
+func testCommands() command.Commands {
+	root := command.NewCommandMust("app", nil, "the app", "", nil)
+	root.AddPersistentOptionMust(option.NewOptionMust("verbose", nil, 'v', nil, "more output", "", false, false, nil))
+	opts := option.NewOptions()
+	opts.AddOptionMust(option.NewOptionMust("port", []string{"listen"}, 'p', nil, "port to listen on", "", true, 8080, nil))
+	opts.AddOptionMust(option.NewOptionMust("dir", nil, 0, nil, "directory | path", "", true, "-", nil))
+	serve := command.NewCommandMust("serve", []string{"srv"}, "run the server", ".Serve runs the server\nuntil interrupted.", opts)
+	serve.AddArgMust(command.NewArgMust[string]("addr", "address to listen on", 1, 1))
+	serve.AddArgMust(command.NewArgMust[string]("dir", "directories to serve", 0, -1))
+	serve.AddSubcommandMust(command.NewCommandMust("status", []string{"st"}, "show status", "", nil))
+	serve.AddSubcommandMust(command.NewCommandMust("reload", nil, "reload config", "", nil))
+	root.AddSubcommandMust(serve)
+	cmds := command.NewCommands()
+	cmds.AddCommandMust(root)
+	return cmds
+}
+
+func TestWriteMan(t *testing.T) {
+	pages := Pages(testCommands(), "prog")
+	var names []string
+	for _, pg := range pages {
+		names = append(names, pg.ManName())
+	}
+	wantNames := []string{"prog-app", "prog-app-serve", "prog-app-serve-status", "prog-app-serve-reload"}
+	if !slices.Equal(names, wantNames) {
+		t.Fatalf("Pages() names = %v, want %v", names, wantNames)
+	}
+	var builder strings.Builder
+	if err := pages[1].WriteMan(&builder); err != nil {
+		t.Fatalf("WriteMan returned error %v", err)
+	}
+	want := `.TH "PROG\-APP\-SERVE" "1" "" "" ""
+.SH NAME
+prog\-app\-serve \- run the server
+.SH SYNOPSIS
+.B prog app serve
+[options] [command] <addr> [dir...]
+.SH DESCRIPTION
+\&.Serve runs the server
+until interrupted.
+.SH ALIASES
+srv
+.SH ARGUMENTS
+.TP
+\fB<addr>\fR \fIstring\fR
+address to listen on (count 1)
+.TP
+\fB[dir...]\fR \fIstring\fR
+directories to serve (count any)
+.SH OPTIONS
+.TP
+\fB\-p\fR, \fB\-\-port\fR, \fB\-\-listen\fR \fIint\fR
+port to listen on (default 8080)
+.TP
+\fB\-\-dir\fR \fIstring\fR
+directory | path (default \-)
+.SH INHERITED OPTIONS
+.TP
+\fB\-v\fR, \fB\-\-verbose\fR \fIbool\fR
+more output
+.SH COMMANDS
+.TP
+\fBstatus\fR, st
+show status
+.TP
+\fBreload\fR
+reload config
+.SH SEE ALSO
+\fBprog\-app\fR(1), \fBprog\-app\-serve\-status\fR(1), \fBprog\-app\-serve\-reload\fR(1)
+`
+	if builder.String() != want {
+		t.Errorf("WriteMan() =\n%s\nwant\n%s", builder.String(), want)
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	pages := Pages(testCommands(), "prog")
+	var builder strings.Builder
+	if err := pages[1].WriteMarkdown(&builder); err != nil {
+		t.Fatalf("WriteMarkdown returned error %v", err)
+	}
+	want := "# prog app serve\n\nrun the server\n\n## Synopsis\n\n```\nprog app serve [options] [command] <addr> [dir...]\n```\n\n" +
+		".Serve runs the server\nuntil interrupted.\n\nAliases: `srv`\n\n" +
+		"## Arguments\n\n| Argument | Type | Count | Description |\n| --- | --- | --- | --- |\n" +
+		"| `<addr>` | string | 1 | address to listen on |\n" +
+		"| `[dir...]` | string | any | directories to serve |\n\n" +
+		"## Options\n\n| Option | Type | Default | Description |\n| --- | --- | --- | --- |\n" +
+		"| `-p`, `--port`, `--listen` | int | `8080` | port to listen on |\n" +
+		"| `--dir` | string | `-` | directory \\| path |\n\n" +
+		"## Inherited options\n\n| Option | Type | Default | Description |\n| --- | --- | --- | --- |\n" +
+		"| `-v`, `--verbose` | bool |  | more output |\n\n" +
+		"## Commands\n\n| Command | Aliases | Description |\n| --- | --- | --- |\n" +
+		"| [status](prog_app_serve_status.md) | `st` | show status |\n" +
+		"| [reload](prog_app_serve_reload.md) |  | reload config |\n\n" +
+		"## See also\n\n* [prog app](prog_app.md) - the app\n"
+	if builder.String() != want {
+		t.Errorf("WriteMarkdown() =\n%s\nwant\n%s", builder.String(), want)
+	}
+}
+
+func TestGenTree(t *testing.T) {
+	dir := t.TempDir()
+	if err := GenManTree(testCommands(), "prog", dir); err != nil {
+		t.Fatalf("GenManTree returned error %v", err)
+	}
+	if err := GenMarkdownTree(testCommands(), "prog", dir); err != nil {
+		t.Fatalf("GenMarkdownTree returned error %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, entry := range entries {
+		files = append(files, entry.Name())
+	}
+	want := []string{
+		"prog-app-serve-reload.1", "prog-app-serve-status.1", "prog-app-serve.1", "prog-app.1",
+		"prog_app.md", "prog_app_serve.md", "prog_app_serve_reload.md", "prog_app_serve_status.md",
+	}
+	if !slices.Equal(files, want) {
+		t.Fatalf("generated files = %v, want %v", files, want)
+	}
+	// the output depends only on the command tree
+	first, err := os.ReadFile(filepath.Join(dir, "prog-app-serve.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := GenManTree(testCommands(), "prog", dir); err != nil {
+		t.Fatalf("GenManTree returned error %v", err)
+	}
+	second, err := os.ReadFile(filepath.Join(dir, "prog-app-serve.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first) != string(second) {
+		t.Errorf("GenManTree output changed between runs")
+	}
+	if err := GenManTree(testCommands(), "prog", filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("GenManTree to a missing directory returned no error")
+	}
+}
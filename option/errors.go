@@ -0,0 +1,49 @@
+package option
+
+import "fmt"
+
+// InvalidValueError is returned when a string cannot be converted to the type of an option or argument.
+type InvalidValueError struct {
+	Name     string // name of the option or argument, "" if not known
+	Token    string // how the value was given, such as "option --port", "environment variable PORT" or "app.conf:3: option port"
+	Value    string // the string that could not be converted
+	Type     string // name of the type it should have
+	Source   Source // where the value came from
+	ArgIndex int    // index of the command line argument holding the value, or -1 if not from the command line
+	Err      error  // the error from a Value's Set method, or nil
+}
+
+// Error returns the message, naming the token if it is known
+func (e *InvalidValueError) Error() string {
+	msg := fmt.Sprintf("could not parse %s as %s", e.Value, e.Type)
+	if e.Token != "" {
+		msg = e.Token + ": " + msg
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the error from a Value's Set method, or nil
+func (e *InvalidValueError) Unwrap() error {
+	return e.Err
+}
+
+// ConstraintError is returned by Group.Check when the options given break the group's constraint.
+type ConstraintError struct {
+	Kind    GroupKind // the kind of group
+	Given   []string  // the options of the group that were given, named as given, such as -f or --url
+	Missing []string  // the options of the group that were not given, as --name
+}
+
+// Error returns the message for the kind of group
+func (e *ConstraintError) Error() string {
+	switch {
+	case (e.Kind == MutuallyExclusive || e.Kind == ExactlyOne) && len(e.Given) > 1:
+		return fmt.Sprintf("options %s cannot be used together", joinNames(e.Given, "and"))
+	case (e.Kind == ExactlyOne || e.Kind == AtLeastOne) && len(e.Given) == 0:
+		return fmt.Sprintf("one of options %s is required", joinNames(e.Missing, "or"))
+	}
+	return fmt.Sprintf("%s given without %s", joinNames(e.Given, "and"), joinNames(e.Missing, "and"))
+}
@@ -0,0 +1,144 @@
+package option
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GroupKind is the kind of constraint a Group puts on its options.
+type GroupKind int
+
+const (
+	MutuallyExclusive GroupKind = iota // at most one of the options may be given
+	ExactlyOne                         // exactly one of the options must be given
+	RequiredTogether                   // either all of the options are given or none of them
+	AtLeastOne                         // at least one of the options must be given
+)
+
+// String returns the name of the kind of group
+func (k GroupKind) String() string {
+	switch k {
+	case MutuallyExclusive:
+		return "mutually exclusive"
+	case ExactlyOne:
+		return "exactly one"
+	case RequiredTogether:
+		return "required together"
+	case AtLeastOne:
+		return "at least one"
+	}
+	return "unknown"
+}
+
+// Group is a constraint on which of a set of options may be given together.
+// An option counts as given if it was set on the command line, in the environment or in a configuration file;
+// a default value does not count.
+type Group struct {
+	kind  GroupKind // the constraint
+	names []string  // names of the options in the group
+}
+
+// NewGroup creates a group of the named options. There must be at least two different names.
+// Names are case insensitive and whitespace is trimmed.
+func NewGroup(kind GroupKind, names ...string) (*Group, error) {
+	if kind < MutuallyExclusive || kind > AtLeastOne {
+		return nil, fmt.Errorf("option.NewGroup called with unknown kind %d", kind)
+	}
+	trimmed := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			return nil, fmt.Errorf("option.NewGroup called with a blank name")
+		}
+		for _, existing := range trimmed {
+			if existing == name {
+				return nil, fmt.Errorf("option.NewGroup called with duplicate name %s", name)
+			}
+		}
+		trimmed = append(trimmed, name)
+	}
+	if len(trimmed) < 2 {
+		return nil, fmt.Errorf("option.NewGroup needs at least two options")
+	}
+	return &Group{kind: kind, names: trimmed}, nil
+}
+
+// NewGroupMust is like NewGroup but panics if there is an error.
+func NewGroupMust(kind GroupKind, names ...string) *Group {
+	g, err := NewGroup(kind, names...)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// Kind returns the kind of constraint of the group
+func (g *Group) Kind() GroupKind {
+	return g.kind
+}
+
+// Names returns the names of the options in the group
+func (g *Group) Names() []string {
+	return g.names
+}
+
+// Check checks the group against parsed options, which must include all the options of the group.
+// The error is a *ConstraintError naming the options as they were given, such as -f or the environment variable FILE.
+func (g *Group) Check(parsed ParsedOptions) error {
+	given := make([]string, 0)
+	missing := make([]string, 0)
+	for _, name := range g.names {
+		po := parsed.GetParsedOption(name)
+		if po == nil {
+			return fmt.Errorf("option group has no option %s", name)
+		}
+		if po.IsSet() {
+			given = append(given, po.givenName())
+		} else {
+			missing = append(missing, "--"+name)
+		}
+	}
+	violated := false
+	switch g.kind {
+	case MutuallyExclusive:
+		violated = len(given) > 1
+	case ExactlyOne:
+		violated = len(given) != 1
+	case RequiredTogether:
+		violated = len(given) > 0 && len(missing) > 0
+	case AtLeastOne:
+		violated = len(given) == 0
+	}
+	if violated {
+		return &ConstraintError{Kind: g.kind, Given: given, Missing: missing}
+	}
+	return nil
+}
+
+// givenName describes how a parsed option was given: as invoked on the command line,
+// or the environment variable or configuration file it came from
+func (po *ParsedOption) givenName() string {
+	switch po.source {
+	case SourceCommandLine:
+		if len([]rune(po.invokedName)) == 1 {
+			return "-" + po.invokedName
+		}
+		return "--" + po.invokedName
+	case SourceEnv:
+		return fmt.Sprintf("--%s (from environment variable %s)", po.name, po.origin)
+	case SourceConfig:
+		return fmt.Sprintf("--%s (from %s:%d)", po.name, po.origin, po.line)
+	}
+	return "--" + po.name
+}
+
+// joinNames joins names as a list: "", "a", "a and b", "a, b and c"
+func joinNames(names []string, conjunction string) string {
+	switch len(names) {
+	case 0:
+		return ""
+	case 1:
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " " + conjunction + " " + names[len(names)-1]
+}
@@ -2,8 +2,13 @@ package option
 
 import (
 	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 	"unicode"
 	"unicode/utf8"
 )
@@ -14,10 +19,11 @@ import (
 // The short name must be a single character, or empty "" meaning no shortname. It is case sensitive.
 // You must use the --flag=false form to turn off a boolean flag.
 // -- is used to separate the flags from the arguments.
-// Integer flags accept 1234, 0664, 0x1234 and may be negative.
+// Integer flags accept 1234, 0664, 0x1234 and may be negative. Unsigned integer flags accept the same but not negative.
+// Float flags accept any input valid for strconv.ParseFloat.
 // Boolean flags may be 1, 0, t, f, T, F, true, false, TRUE, FALSE, True, False.
-// TODO Duration flags accept any input valid for time.ParseDuration.
-// TODO []string flags accept a list of comma-separated strings.
+// Duration flags accept any input valid for time.ParseDuration.
+// []string flags accept a list of comma-separated strings. Empty items are dropped, so an empty string is an empty list.
 type Option struct {
 	name            string   // name of option
 	aliases         []string // alias names
@@ -28,17 +34,49 @@ type Option struct {
 	hasDefault      bool     // true if option has a default value
 	value           any      // default value and type of option; also holds the current value
 	// the value is an interface and its type is the type of the value, constrainted to OptionTypes
-	handler OptionHandler // handler to call for this option, or nil if none
+	handler   OptionHandler // handler to call for this option, or nil if none
+	envVars   []string      // environment variables that can set this option, in order of precedence
+	completer Completer     // function that offers values for shell completion, or nil if none
+	required  bool          // true if the option must be given on the command line, in the environment or in a configuration file
 }
 
+// Completion is a candidate value offered by shell completion, with a description that some shells show.
+type Completion struct {
+	Value       string
+	Description string
+}
+
+// ParsedState is the partially parsed command line given to a Completer.
+// *command.ParsedCommands implements it.
+type ParsedState interface {
+	LookupOption(name string) *ParsedOption // finds a parsed option of the invoked commands by name or alias, or nil
+	Args() []string                         // the command line arguments after the commands and options
+}
+
+// Completer returns the candidates for completing the value of an option during shell completion,
+// given the command line parsed so far and the part of the value already typed.
+type Completer func(parsed ParsedState, prefix string) []Completion
+
 // OptionHandler is a function that handles an option when it is set.
 // It returns an error if there was a problem handling the option.
 type OptionHandler func(opt *Option) error
 
+// Value is the interface for option values of user-defined types, in the manner of flag.Value.
+// Set parses a string and sets the value, String formats the value,
+// and Type returns a short name for the type that is shown in help output, such as "ip" or "level".
+// A Value must be a pointer. Each time an option is parsed, Set is called on a fresh copy of the default value.
+// The copy is shallow, so a Value holding a slice or map must not modify it in place in Set,
+// unless the Value has a Clone method returning a deep copy, which is then used to make the fresh copy.
+// A Value that also has an IsBoolFlag method returning true can be given without a value, like a bool.
+type Value interface {
+	String() string
+	Set(string) error
+	Type() string
+}
+
 // OptionTypes is a constraint on the types of option values.
-// TODO support float, float64, []string, duration
 type OptionTypes interface {
-	int | int64 | string | bool
+	int | int64 | uint | uint64 | float64 | string | bool | time.Duration | []string
 }
 
 // Options is a set of options.
@@ -46,11 +84,16 @@ type Options []*Option
 
 // ParsedOption is a single parsed option.
 type ParsedOption struct {
-	name        string // actual option name, not an alias
-	invokedName string // name as invoked on command line (could be alias or short name)
-	isDefault   bool   // true if default value was used
-	isSet       bool   // true if option was set explicitly
-	value       any    // actual option value, either set or default
+	name        string  // actual option name, not an alias
+	invokedName string  // name as invoked on command line (could be alias or short name)
+	isDefault   bool    // true if default value was used
+	isSet       bool    // true if option was set explicitly
+	value       any     // actual option value, either set or default
+	source      Source  // where the value came from
+	origin      string  // environment variable or configuration file the value came from, or "" if neither
+	line        int     // line in the configuration file the value came from
+	argIndex    int     // index of the command line argument the value came from, or -1 if none
+	opt         *Option // the option this was parsed from
 }
 
 // ParsedOptions is a set of parsed options.
@@ -86,6 +129,69 @@ func (opt *Option) LongDescription() string {
 	return opt.longDescription
 }
 
+// CommandLineNames returns the ways the option is written on the command line: the short name and short aliases
+// with a single dash, then the name and aliases with a double dash, for example -p, --port, --listen-port.
+func (opt *Option) CommandLineNames() []string {
+	names := make([]string, 0)
+	if opt.shortName != 0 {
+		names = append(names, "-"+string(opt.shortName))
+		for _, r := range opt.shortAliases {
+			names = append(names, "-"+string(r))
+		}
+	}
+	names = append(names, "--"+opt.name)
+	for _, alias := range opt.aliases {
+		names = append(names, "--"+alias)
+	}
+	return names
+}
+
+// Completer returns the function that offers values of the option for shell completion, or nil if none.
+func (opt *Option) Completer() Completer {
+	return opt.completer
+}
+
+// SetCompleter sets the function that offers values of the option for shell completion.
+func (opt *Option) SetCompleter(completer Completer) {
+	opt.completer = completer
+}
+
+// HasDefault returns true if the option has a default value.
+func (opt *Option) HasDefault() bool {
+	return opt.hasDefault
+}
+
+// DefaultString returns the default value of the option formatted for display, or "" if there is no default.
+func (opt *Option) DefaultString() string {
+	if !opt.hasDefault {
+		return ""
+	}
+	return formatValue(opt.value)
+}
+
+// IsRequired returns true if the option must be given.
+func (opt *Option) IsRequired() bool {
+	return opt.required
+}
+
+// SetRequired sets whether the option must be given on the command line, in the environment or in a configuration file.
+// A default value does not satisfy a required option.
+func (opt *Option) SetRequired(required bool) {
+	opt.required = required
+}
+
+// EnvVars returns the names of the environment variables that can set the option.
+func (opt *Option) EnvVars() []string {
+	return opt.envVars
+}
+
+// SetEnvVars sets the names of the environment variables that can set the option, in order of precedence.
+// Environment variable names are case sensitive and are not trimmed or folded.
+// A value on the command line takes precedence over an environment variable, which takes precedence over the default.
+func (opt *Option) SetEnvVars(names ...string) {
+	opt.envVars = names
+}
+
 // NewOption creates a new option.
 // It is a generic function that sets the default value whose type is carried because it is saved as an interface{}.
 // The name and all aliases must not be all whitespace. Whitespace is trimmed.
@@ -94,10 +200,40 @@ func (opt *Option) LongDescription() string {
 // There must be a short name if there are short name aliases.
 // The short name and all short name aliases must be non-whitespace characters and unique.
 // The description and long description can be empty strings.
-// The value must be one of the types in OptionTypes: int, int64, string, or bool.
+// The value must be one of the types in OptionTypes: int, int64, uint, uint64, float64, string, bool, time.Duration or []string.
 // Unicode runes and strings are supported.
 // Returns an error if anything is not valid.
 func NewOption[V OptionTypes](nm string, al []string, sn rune, sa []rune, desc string, longdesc string, hasDef bool, value V, handler OptionHandler) (*Option, error) {
+	// note that the value is constrained by the compiler to be one of the allowed types
+	return newOption(nm, al, sn, sa, desc, longdesc, hasDef, value, handler)
+}
+
+// NewValueOption creates a new option whose value is a user-defined type implementing Value.
+// The rules for names, aliases, short names and descriptions are the same as for NewOption.
+// The value must be a non-nil pointer, it holds the default value and is never modified by parsing.
+// Returns an error if anything is not valid.
+func NewValueOption(nm string, al []string, sn rune, sa []rune, desc string, longdesc string, hasDef bool, value Value, handler OptionHandler) (*Option, error) {
+	if value == nil {
+		return nil, fmt.Errorf("option.NewValueOption called with nil value")
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return nil, fmt.Errorf("option.NewValueOption called with value of type %T, which is not a non-nil pointer", value)
+	}
+	return newOption(nm, al, sn, sa, desc, longdesc, hasDef, value, handler)
+}
+
+// NewValueOptionMust is like NewValueOption but panics if there is an error.
+func NewValueOptionMust(nm string, al []string, sn rune, sa []rune, desc string, longdesc string, hasDef bool, value Value, handler OptionHandler) *Option {
+	opt, err := NewValueOption(nm, al, sn, sa, desc, longdesc, hasDef, value, handler)
+	if err != nil {
+		panic(err)
+	}
+	return opt
+}
+
+// newOption does the work of NewOption and NewValueOption, the type of value has already been checked.
+func newOption(nm string, al []string, sn rune, sa []rune, desc string, longdesc string, hasDef bool, value any, handler OptionHandler) (*Option, error) {
 	// Trim and lowercase the name and aliases, check for duplicates or single character names/aliases
 	name := strings.ToLower(strings.TrimSpace(nm)) // names are case insensitive
 	nameLength := utf8.RuneCountInString(name)
@@ -168,7 +304,6 @@ func NewOption[V OptionTypes](nm string, al []string, sn rune, sa []rune, desc s
 			achk[r] = struct{}{}
 		}
 	}
-	// note that the value is constrained by the compiler to be one of the allowed types
 	// if we got here, all seems OK for this option, create the option
 	opt := &Option{
 		name:            name,
@@ -303,6 +438,13 @@ func GetParsedValue[V OptionTypes](f *ParsedOption) (V, bool) {
 	return v, ok
 }
 
+// GetParsedValueAs is a generic function to get the value of a parsed option whose type is a user-defined Value.
+// returns false if the type of the value is not what was expected.
+func GetParsedValueAs[V Value](f *ParsedOption) (V, bool) {
+	v, ok := f.value.(V)
+	return v, ok
+}
+
 // GetParsedValueAny gets the value of a parsed option as an interface{}.
 func (opt *ParsedOption) GetParsedValueAny() any {
 	return opt.value
@@ -318,36 +460,151 @@ func GetParsedValueMust[V OptionTypes](f *ParsedOption) V {
 	return v
 }
 
+// IsBool returns true if the option takes a boolean value.
+// Boolean options can be given without a value on the command line.
+func (opt *Option) IsBool() bool {
+	switch v := opt.value.(type) {
+	case bool:
+		return true
+	case interface{ IsBoolFlag() bool }:
+		return v.IsBoolFlag()
+	}
+	return false
+}
+
+// TypeName returns the name of the type of the option value, as shown in help output.
+// For a Value, this is the name returned by its Type method.
+func (opt *Option) TypeName() string {
+	return typeName(opt.value)
+}
+
 // ParseValue sets the value of a option from a string.
+// An error converting the string is an *InvalidValueError.
 func (opt *Option) ParseValue(s string) error {
-	switch v := opt.value.(type) {
+	v, err := convertValue(opt.value, s)
+	if err != nil {
+		return fmt.Errorf("option.ParseValue: %w", withToken(err, opt.name, "option --"+opt.name, SourceNone, -1))
+	}
+	opt.value = v
+	return nil
+}
+
+// ConvertValue converts a string to a value of type V in the same way as an option value, for example for a command argument.
+// An error converting the string is an *InvalidValueError.
+func ConvertValue[V OptionTypes](s string) (V, error) {
+	var zero V
+	v, err := convertValue(zero, s)
+	if err != nil {
+		return zero, err
+	}
+	return v.(V), nil
+}
+
+// TypeNameOf returns the name of the type V, as shown in help output.
+func TypeNameOf[V OptionTypes]() string {
+	var zero V
+	return typeName(zero)
+}
+
+// convertValue converts a string to a value of the same type as typed, which must be one of the OptionTypes.
+// typed is only used for its type, it is not modified.
+func convertValue(typed any, s string) (any, error) {
+	switch v := typed.(type) {
+	case Value:
+		nv := cloneValue(v)
+		if err := nv.Set(s); err != nil {
+			return nil, &InvalidValueError{Value: s, Type: v.Type(), ArgIndex: -1, Err: err}
+		}
+		return nv, nil
 	case int:
-		n, err := fmt.Sscanf(s, "%d", &v)
-		if err != nil || n != 1 {
-			return fmt.Errorf("option.ParseValue: could not parse %s as int", s)
+		n, err := strconv.ParseInt(s, 0, strconv.IntSize)
+		if err != nil {
+			return nil, &InvalidValueError{Value: s, Type: "int", ArgIndex: -1}
 		}
-		opt.value = v
+		return int(n), nil
 	case int64:
-		n, err := fmt.Sscanf(s, "%d", &v)
-		if err != nil || n != 1 {
-			return fmt.Errorf("option.ParseValue: could not parse %s as int64", s)
+		n, err := strconv.ParseInt(s, 0, 64)
+		if err != nil {
+			return nil, &InvalidValueError{Value: s, Type: "int64", ArgIndex: -1}
+		}
+		return n, nil
+	case uint:
+		n, err := strconv.ParseUint(s, 0, strconv.IntSize)
+		if err != nil {
+			return nil, &InvalidValueError{Value: s, Type: "uint", ArgIndex: -1}
+		}
+		return uint(n), nil
+	case uint64:
+		n, err := strconv.ParseUint(s, 0, 64)
+		if err != nil {
+			return nil, &InvalidValueError{Value: s, Type: "uint64", ArgIndex: -1}
+		}
+		return n, nil
+	case float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, &InvalidValueError{Value: s, Type: "float64", ArgIndex: -1}
+		}
+		return f, nil
+	case time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, &InvalidValueError{Value: s, Type: "duration", ArgIndex: -1}
 		}
-		opt.value = v
+		return d, nil
+	case []string:
+		list := make([]string, 0)
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list, nil
 	case string:
-		opt.value = s
+		return s, nil
 	case bool:
 		switch s {
 		case "true", "True", "TRUE", "t", "T", "1":
-			opt.value = true
+			return true, nil
 		case "false", "False", "FALSE", "f", "F", "0":
-			opt.value = false
+			return false, nil
 		default:
-			return fmt.Errorf("option.ParseValue: could not parse %s as bool", s)
+			return nil, &InvalidValueError{Value: s, Type: "bool", ArgIndex: -1}
 		}
 	default:
-		return fmt.Errorf("option.ParseValue: unknown type %T", v)
+		return nil, fmt.Errorf("option.ParseValue: unknown type %T", v)
 	}
-	return nil
+}
+
+// cloneValue returns a pointer to a copy of what the Value v points to, so that Set does not modify v.
+// A Value with a Clone method makes its own copy.
+func cloneValue(v Value) Value {
+	if c, ok := v.(interface{ Clone() Value }); ok {
+		return c.Clone()
+	}
+	rv := reflect.ValueOf(v)
+	nv := reflect.New(rv.Elem().Type())
+	nv.Elem().Set(rv.Elem())
+	return nv.Interface().(Value)
+}
+
+// formatValue formats an option value for display, showing a []string as a comma-separated list.
+func formatValue(v any) string {
+	switch val := v.(type) {
+	case Value:
+		return val.String()
+	case []string:
+		return strings.Join(val, ",")
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// typeName returns the name of the type of an option value for display.
+func typeName(v any) string {
+	if val, ok := v.(Value); ok {
+		return val.Type()
+	}
+	return fmt.Sprintf("%T", v)
 }
 
 // NewOptions creates a new empty set of options.
@@ -375,9 +632,9 @@ func (fs Options) String() string {
 			sa = append(sa, sas)
 		}
 		if f.hasDefault {
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%v\t%T\t%s\t%s\n", f.name, strings.Join(f.aliases, ","), sn, strings.Join(sa, ","), f.value, f.value, f.description, f.longDescription)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", f.name, strings.Join(f.aliases, ","), sn, strings.Join(sa, ","), formatValue(f.value), typeName(f.value), f.description, f.longDescription)
 		} else {
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t<none>\t%T\t%s\t%s\n", f.name, strings.Join(f.aliases, ","), sn, strings.Join(sa, ","), f.value, f.description, f.longDescription)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t<none>\t%s\t%s\t%s\n", f.name, strings.Join(f.aliases, ","), sn, strings.Join(sa, ","), typeName(f.value), f.description, f.longDescription)
 		}
 	}
 	w.Flush()
@@ -389,9 +646,177 @@ func NewParsedOptions() ParsedOptions {
 	return make(ParsedOptions)
 }
 
+// NewParsedOption creates a parsed option for an Option, holding the option's default value.
+// If the option has no default, the value is the option's initial value, which carries its type,
+// the parsed option is neither default nor set, and its source is SourceNone.
+func NewParsedOption(opt *Option) *ParsedOption {
+	po := &ParsedOption{
+		name:      opt.name,
+		isDefault: opt.hasDefault,
+		isSet:     false,
+		value:     opt.value,
+		source:    SourceNone,
+		argIndex:  -1,
+		opt:       opt,
+	}
+	if opt.hasDefault {
+		po.source = SourceDefault
+	}
+	return po
+}
+
+// set parses the string s according to the type of the option and records it as explicitly set from source.
+// token describes where s came from for an error, and argIndex is the index of the command line argument or -1.
+// The Option itself is not modified.
+func (po *ParsedOption) set(source Source, invokedName string, token string, argIndex int, s string) error {
+	v, err := convertValue(po.opt.value, s)
+	if err != nil {
+		return withToken(err, po.name, token, source, argIndex)
+	}
+	po.invokedName = invokedName
+	po.value = v
+	po.isDefault = false
+	po.isSet = true
+	po.source = source
+	po.origin = ""
+	po.line = 0
+	po.argIndex = argIndex
+	return nil
+}
+
+// withToken fills in where the value in an *InvalidValueError came from
+func withToken(err error, name string, token string, source Source, argIndex int) error {
+	if ive, ok := err.(*InvalidValueError); ok {
+		ive.Name = name
+		ive.Token = token
+		ive.Source = source
+		ive.ArgIndex = argIndex
+	}
+	return err
+}
+
+// SetValue parses the string s from the command line according to the type of the option and records it as explicitly set.
+// invokedName is the name, alias, short name or short alias used on the command line,
+// and argIndex is the index of the command line argument that set it.
+// An error converting the string is an *InvalidValueError.
+func (po *ParsedOption) SetValue(invokedName string, argIndex int, s string) error {
+	token := "option --" + invokedName
+	if utf8.RuneCountInString(invokedName) == 1 {
+		token = "option -" + invokedName
+	}
+	return po.set(SourceCommandLine, invokedName, token, argIndex, s)
+}
+
+// SetEnvValue parses the string s from the environment variable envVar according to the type of the option
+// and records it as explicitly set. The invoked name is the name of the environment variable.
+func (po *ParsedOption) SetEnvValue(envVar string, s string) error {
+	if err := po.set(SourceEnv, envVar, "environment variable "+envVar, -1, s); err != nil {
+		return err
+	}
+	po.origin = envVar
+	return nil
+}
+
+// SetConfigValue parses the string s from line of the configuration file path according to the type of the option
+// and records it as explicitly set. The invoked name is the option name.
+func (po *ParsedOption) SetConfigValue(path string, line int, s string) error {
+	if err := po.set(SourceConfig, po.name, fmt.Sprintf("%s:%d: option %s", path, line, po.name), -1, s); err != nil {
+		return err
+	}
+	po.origin = path
+	po.line = line
+	return nil
+}
+
+// Override sets the value of the parsed option from a program, replacing any other value.
+// The value must have the same type as the option.
+func (po *ParsedOption) Override(value any) error {
+	if reflect.TypeOf(value) != reflect.TypeOf(po.opt.value) {
+		return fmt.Errorf("option.Override: option %s has type %s but value has type %T", po.name, typeName(po.opt.value), value)
+	}
+	po.invokedName = po.name
+	po.value = value
+	po.isDefault = false
+	po.isSet = true
+	po.source = SourceOverride
+	po.origin = ""
+	po.line = 0
+	po.argIndex = -1
+	return nil
+}
+
+// Source returns where the value of the parsed option came from.
+func (po *ParsedOption) Source() Source {
+	return po.source
+}
+
+// ArgIndex returns the index of the command line argument that set the value, or -1 if it did not come from the command line.
+// The index is into the arguments given to the parser, which usually do not include the program name.
+func (po *ParsedOption) ArgIndex() int {
+	return po.argIndex
+}
+
+// ConfigFile returns the path and line of the configuration file the value came from, or "" if it did not come from a file.
+func (po *ParsedOption) ConfigFile() (string, int) {
+	if po.source != SourceConfig {
+		return "", 0
+	}
+	return po.origin, po.line
+}
+
+// EnvVar returns the name of the environment variable the value came from, or "" if it did not come from the environment.
+func (po *ParsedOption) EnvVar() string {
+	if po.source != SourceEnv {
+		return ""
+	}
+	return po.origin
+}
+
+// Origin returns a description of where the value came from, for example
+// "command line argument 2 (--port)", "environment variable PORT" or "config file app.conf line 5".
+func (po *ParsedOption) Origin() string {
+	switch po.source {
+	case SourceCommandLine:
+		if utf8.RuneCountInString(po.invokedName) == 1 {
+			return fmt.Sprintf("%s argument %d (-%s)", po.source, po.argIndex, po.invokedName)
+		}
+		return fmt.Sprintf("%s argument %d (--%s)", po.source, po.argIndex, po.invokedName)
+	case SourceEnv:
+		return fmt.Sprintf("%s %s", po.source, po.origin)
+	case SourceConfig:
+		return fmt.Sprintf("%s %s line %d", po.source, po.origin, po.line)
+	}
+	return po.source.String()
+}
+
+// Name returns the actual name of the parsed option, not an alias.
+func (po *ParsedOption) Name() string {
+	return po.name
+}
+
+// InvokedName returns the name, alias, short name or short alias used to set the option, or "" if not set.
+func (po *ParsedOption) InvokedName() string {
+	return po.invokedName
+}
+
+// IsDefault returns true if the value is the option's default value.
+func (po *ParsedOption) IsDefault() bool {
+	return po.isDefault
+}
+
+// IsSet returns true if the value was set explicitly.
+func (po *ParsedOption) IsSet() bool {
+	return po.isSet
+}
+
+// Option returns the Option that this parsed option was created from.
+func (po *ParsedOption) Option() *Option {
+	return po.opt
+}
+
 // GetParsedOption gets a parsed option by name, returning nil if the option does not exist.
-func (ps *ParsedOptions) GetParsedOption(name string) *ParsedOption {
-	opt, ok := (*ps)[name]
+func (ps ParsedOptions) GetParsedOption(name string) *ParsedOption {
+	opt, ok := ps[name]
 	if ok {
 		return opt
 	}
@@ -399,13 +824,15 @@ func (ps *ParsedOptions) GetParsedOption(name string) *ParsedOption {
 }
 
 // String returns a string representation of a ParsedOptions, useful for debugging.
+// The options are listed in order of name.
 func (ps ParsedOptions) String() string {
 	s := strings.Builder{}
 	s.WriteString("ParsedOptions:\n")
 	w := tabwriter.NewWriter(&s, 1, 1, 1, ' ', 0)
-	fmt.Fprintln(w, "Name\tInvoked Name\tDefault?\tSet?\tValue\tType")
-	for _, p := range ps {
-		fmt.Fprintf(w, "%s\t%s\t%t\t%t\t%v\t%T\n", p.name, p.invokedName, p.isDefault, p.isSet, p.value, p.value)
+	fmt.Fprintln(w, "Name\tInvoked Name\tDefault?\tSet?\tValue\tType\tOrigin")
+	for _, name := range slices.Sorted(maps.Keys(ps)) {
+		p := ps[name]
+		fmt.Fprintf(w, "%s\t%s\t%t\t%t\t%s\t%s\t%s\n", p.name, p.invokedName, p.isDefault, p.isSet, formatValue(p.value), typeName(p.value), p.Origin())
 	}
 	w.Flush()
 	return s.String()
@@ -0,0 +1,260 @@
+package option
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     *Option
+		input   string
+		want    any
+		wantErr bool
+	}{
+		{name: "int decimal", opt: NewOptionMust("num", nil, 0, nil, "", "", false, 0, nil), input: "-42", want: -42},
+		{name: "int hex", opt: NewOptionMust("num", nil, 0, nil, "", "", false, 0, nil), input: "0x1f", want: 31},
+		{name: "int octal", opt: NewOptionMust("num", nil, 0, nil, "", "", false, 0, nil), input: "0664", want: 0664},
+		{name: "int bad", opt: NewOptionMust("num", nil, 0, nil, "", "", false, 0, nil), input: "12abc", wantErr: true},
+		{name: "int64", opt: NewOptionMust("num", nil, 0, nil, "", "", false, int64(0), nil), input: "9000000000", want: int64(9000000000)},
+		{name: "uint", opt: NewOptionMust("num", nil, 0, nil, "", "", false, uint(0), nil), input: "7", want: uint(7)},
+		{name: "uint negative", opt: NewOptionMust("num", nil, 0, nil, "", "", false, uint(0), nil), input: "-7", wantErr: true},
+		{name: "uint64", opt: NewOptionMust("num", nil, 0, nil, "", "", false, uint64(0), nil), input: "0x10", want: uint64(16)},
+		{name: "float64", opt: NewOptionMust("num", nil, 0, nil, "", "", false, 0.0, nil), input: "2.5e3", want: 2500.0},
+		{name: "float64 bad", opt: NewOptionMust("num", nil, 0, nil, "", "", false, 0.0, nil), input: "x", wantErr: true},
+		{name: "duration", opt: NewOptionMust("timeout", nil, 0, nil, "", "", false, time.Second, nil), input: "1m30s", want: 90 * time.Second},
+		{name: "duration bad", opt: NewOptionMust("timeout", nil, 0, nil, "", "", false, time.Second, nil), input: "30", wantErr: true},
+		{name: "string list", opt: NewOptionMust("hosts", nil, 0, nil, "", "", false, []string{}, nil), input: "a.com, b.com,c.com", want: []string{"a.com", "b.com", "c.com"}},
+		{name: "string list empty", opt: NewOptionMust("hosts", nil, 0, nil, "", "", false, []string{"x"}, nil), input: "", want: []string{}},
+		{name: "string list empty items", opt: NewOptionMust("hosts", nil, 0, nil, "", "", false, []string{}, nil), input: "a,, b,", want: []string{"a", "b"}},
+		{name: "bool", opt: NewOptionMust("flag", nil, 0, nil, "", "", false, false, nil), input: "T", want: true},
+		{name: "bool bad", opt: NewOptionMust("flag", nil, 0, nil, "", "", false, false, nil), input: "yes", wantErr: true},
+		{name: "string", opt: NewOptionMust("name", nil, 0, nil, "", "", false, "", nil), input: "a b", want: "a b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opt.ParseValue(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseValue(%q) did not return an error", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseValue(%q) returned error %v", tt.input, err)
+			}
+			if got := tt.opt.GetValueAny(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseValue(%q) = %#v, want %#v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsedOptionSetValue(t *testing.T) {
+	opt := NewOptionMust("timeout", []string{"to"}, 't', nil, "", "", true, 5*time.Second, nil)
+	po := NewParsedOption(opt)
+	if !po.IsDefault() || po.IsSet() {
+		t.Errorf("NewParsedOption isDefault = %v, isSet = %v, want true, false", po.IsDefault(), po.IsSet())
+	}
+	if err := po.SetValue("to", 3, "250ms"); err != nil {
+		t.Fatalf("SetValue returned error %v", err)
+	}
+	if got := GetParsedValueMust[time.Duration](po); got != 250*time.Millisecond {
+		t.Errorf("SetValue value = %v, want 250ms", got)
+	}
+	if po.IsDefault() || !po.IsSet() || po.InvokedName() != "to" {
+		t.Errorf("SetValue isDefault = %v, isSet = %v, invokedName = %q", po.IsDefault(), po.IsSet(), po.InvokedName())
+	}
+	if got := GetValueMust[time.Duration](opt); got != 5*time.Second {
+		t.Errorf("SetValue changed the option default to %v", got)
+	}
+}
+
+// level is a user-defined option value type used to test Value options
+type level int
+
+func (l *level) String() string {
+	return [...]string{"debug", "info", "error"}[*l]
+}
+
+func (l *level) Set(s string) error {
+	switch s {
+	case "debug":
+		*l = 0
+	case "info":
+		*l = 1
+	case "error":
+		*l = 2
+	default:
+		return fmt.Errorf("unknown level %s", s)
+	}
+	return nil
+}
+
+func (l *level) Type() string {
+	return "level"
+}
+
+func TestValueOption(t *testing.T) {
+	def := level(1)
+	opt, err := NewValueOption("level", nil, 'l', nil, "log level", "", true, &def, nil)
+	if err != nil {
+		t.Fatalf("NewValueOption returned error %v", err)
+	}
+	if opt.TypeName() != "level" || opt.IsBool() {
+		t.Errorf("TypeName = %q, IsBool = %v, want level, false", opt.TypeName(), opt.IsBool())
+	}
+	po := NewParsedOption(opt)
+	if err := po.SetValue("l", 1, "error"); err != nil {
+		t.Fatalf("SetValue returned error %v", err)
+	}
+	got, ok := GetParsedValueAs[*level](po)
+	if !ok || *got != 2 {
+		t.Errorf("GetParsedValueAs = %v, %v, want error, true", got, ok)
+	}
+	if def != 1 {
+		t.Errorf("SetValue modified the default value to %s", def.String())
+	}
+	if err := po.SetValue("l", 1, "loud"); err == nil {
+		t.Errorf("SetValue did not return an error for an invalid level")
+	}
+	if !strings.Contains(Options{opt}.String(), "level") {
+		t.Errorf("Options.String() does not show the type name:\n%s", Options{opt}.String())
+	}
+	if _, err := NewValueOption("level", nil, 0, nil, "", "", false, nil, nil); err == nil {
+		t.Errorf("NewValueOption did not return an error for a nil value")
+	}
+}
+
+// labels is a map-backed Value that adds a key=value pair each time it is set, and so needs a Clone method
+type labels map[string]string
+
+func (l *labels) String() string {
+	return fmt.Sprint(*l)
+}
+
+func (l *labels) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("%s is not key=value", s)
+	}
+	(*l)[key] = value
+	return nil
+}
+
+func (l *labels) Type() string {
+	return "labels"
+}
+
+func (l *labels) Clone() Value {
+	c := maps.Clone(*l)
+	return &c
+}
+
+func TestValueOptionClone(t *testing.T) {
+	def := labels{"env": "dev"}
+	opt := NewValueOptionMust("label", nil, 0, nil, "labels", "", true, &def, nil)
+	po := NewParsedOption(opt)
+	if err := po.SetValue("label", 1, "team=core"); err != nil {
+		t.Fatalf("SetValue returned error %v", err)
+	}
+	got, ok := GetParsedValueAs[*labels](po)
+	if !ok || !reflect.DeepEqual(*got, labels{"env": "dev", "team": "core"}) {
+		t.Errorf("GetParsedValueAs = %v, %v, want map[env:dev team:core], true", got, ok)
+	}
+	if !reflect.DeepEqual(def, labels{"env": "dev"}) {
+		t.Errorf("SetValue modified the default value to %v", def)
+	}
+}
+
+func TestParsedOptionSource(t *testing.T) {
+	opt := NewOptionMust("port", nil, 'p', nil, "", "", true, 8080, nil)
+	noDefault := NewOptionMust("name", nil, 0, nil, "", "", false, "", nil)
+	tests := []struct {
+		name       string
+		opt        *Option
+		set        func(po *ParsedOption) error
+		wantSource Source
+		wantOrigin string
+		wantValue  any
+	}{
+		{name: "default", opt: opt, set: func(po *ParsedOption) error { return nil }, wantSource: SourceDefault, wantOrigin: "default", wantValue: 8080},
+		{name: "none", opt: noDefault, set: func(po *ParsedOption) error { return nil }, wantSource: SourceNone, wantOrigin: "none", wantValue: ""},
+		{
+			name:       "config",
+			opt:        opt,
+			set:        func(po *ParsedOption) error { return po.SetConfigValue("app.conf", 5, "8081") },
+			wantSource: SourceConfig,
+			wantOrigin: "config file app.conf line 5",
+			wantValue:  8081,
+		},
+		{
+			name:       "env",
+			opt:        opt,
+			set:        func(po *ParsedOption) error { return po.SetEnvValue("PORT", "8082") },
+			wantSource: SourceEnv,
+			wantOrigin: "environment variable PORT",
+			wantValue:  8082,
+		},
+		{
+			name:       "command line short",
+			opt:        opt,
+			set:        func(po *ParsedOption) error { return po.SetValue("p", 2, "8083") },
+			wantSource: SourceCommandLine,
+			wantOrigin: "command line argument 2 (-p)",
+			wantValue:  8083,
+		},
+		{
+			name:       "command line long",
+			opt:        opt,
+			set:        func(po *ParsedOption) error { return po.SetValue("port", 4, "8084") },
+			wantSource: SourceCommandLine,
+			wantOrigin: "command line argument 4 (--port)",
+			wantValue:  8084,
+		},
+		{
+			name:       "override",
+			opt:        opt,
+			set:        func(po *ParsedOption) error { return po.Override(8085) },
+			wantSource: SourceOverride,
+			wantOrigin: "override",
+			wantValue:  8085,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			po := NewParsedOption(tt.opt)
+			if err := tt.set(po); err != nil {
+				t.Fatalf("setting value returned error %v", err)
+			}
+			if po.Source() != tt.wantSource || po.Origin() != tt.wantOrigin || po.GetParsedValueAny() != tt.wantValue {
+				t.Errorf("got %v from %s (%q), want %v from %s (%q)", po.GetParsedValueAny(), po.Source(), po.Origin(), tt.wantValue, tt.wantSource, tt.wantOrigin)
+			}
+		})
+	}
+	if err := NewParsedOption(opt).Override("8086"); err == nil {
+		t.Errorf("Override did not return an error for a value of the wrong type")
+	}
+}
+
+func TestJoinNames(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  string
+	}{
+		{names: nil, want: ""},
+		{names: []string{"--a"}, want: "--a"},
+		{names: []string{"--a", "--b"}, want: "--a and --b"},
+		{names: []string{"--a", "--b", "--c"}, want: "--a, --b and --c"},
+	}
+	for _, tt := range tests {
+		if got := joinNames(tt.names, "and"); got != tt.want {
+			t.Errorf("joinNames(%q) = %q, want %q", tt.names, got, tt.want)
+		}
+	}
+}
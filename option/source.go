@@ -0,0 +1,33 @@
+package option
+
+// Source is where the value of a parsed option came from.
+// The sources are listed in increasing order of precedence, except that SourceNone means there is no value.
+type Source int
+
+const (
+	SourceNone        Source = iota // the option has no default and was not set
+	SourceDefault                   // the default value of the option
+	SourceConfig                    // a configuration file
+	SourceEnv                       // an environment variable
+	SourceCommandLine               // the command line
+	SourceOverride                  // set by the program with ParsedOption.Override
+)
+
+// String returns the name of the source
+func (s Source) String() string {
+	switch s {
+	case SourceNone:
+		return "none"
+	case SourceDefault:
+		return "default"
+	case SourceConfig:
+		return "config file"
+	case SourceEnv:
+		return "environment variable"
+	case SourceCommandLine:
+		return "command line"
+	case SourceOverride:
+		return "override"
+	}
+	return "unknown"
+}
@@ -0,0 +1,80 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Exit codes returned by ExitCode, following shell conventions: 128 plus the signal number for a signal.
+const (
+	ExitOK        = 0   // success
+	ExitFailure   = 1   // any failure without a more specific code
+	ExitUsage     = 2   // the command line does not fit the command tree
+	ExitInterrupt = 130 // interrupted by SIGINT
+	ExitTerminate = 143 // terminated by SIGTERM
+)
+
+// ExitError is an error that carries the exit code for the program.
+// A handler can return one to choose the exit code; if Err is nil, Main exits with Code without a message.
+type ExitError struct {
+	Code int   // the exit code
+	Err  error // the error, or nil
+}
+
+// NewExitError returns an ExitError with the code and error
+func NewExitError(code int, err error) *ExitError {
+	return &ExitError{Code: code, Err: err}
+}
+
+// Error returns the message of Err, or the exit code if there is no Err
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns Err
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns Code
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// ExitCode returns the exit code for an error returned by Runner.Run: ExitOK for nil,
+// the code of the first error in the chain with an ExitCode method, such as ExitError or UsageError, or else ExitFailure.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var coded interface{ ExitCode() int }
+	if errors.As(err, &coded) {
+		return coded.ExitCode()
+	}
+	return ExitFailure
+}
+
+// UsageError is returned by Runner.Run when the command line does not fit the command tree.
+// Run has already written the message and a pointer to the help to the error output.
+// Err is the *command.ParseError from parsing.
+type UsageError struct {
+	Err error
+}
+
+// Error returns the message of Err
+func (e *UsageError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns Err
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns ExitUsage
+func (e *UsageError) ExitCode() int {
+	return ExitUsage
+}
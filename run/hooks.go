@@ -0,0 +1,62 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/SpencerBrown/go-http/command"
+)
+
+// execute calls the handler of the invoked command with the lifecycle hooks of the invoked commands around it.
+// The pre-run hooks run first: the persistent ones from the top of the tree down, then the invoked command's PreRun.
+// The post-run hooks then run in reverse: the invoked command's PostRun, then the persistent ones from the bottom up.
+// A command's post-run hooks run only if its pre-run hooks succeeded, but they run even if the handler
+// or a later pre-run hook failed, so teardown always matches setup. A failed pre-run hook skips the rest and the handler.
+// A *command.ParseError from the handler, such as from the help command given an unknown path, is reported as a usage error.
+// r is the command.Env for the handler and hooks.
+func execute(ctx context.Context, pc *command.ParsedCommands, r *Runner) error {
+	var env command.Env = r
+	invoked := pc.Commands()
+	post := make([]command.CommandHandler, 0)
+	err := func() error {
+		for i, parsed := range invoked {
+			cmd := parsed.Command()
+			hooks := []command.CommandHandler{cmd.PersistentPreRun()}
+			if i == len(invoked)-1 {
+				hooks = append(hooks, cmd.PreRun())
+			}
+			for _, hook := range hooks {
+				if hook == nil {
+					continue
+				}
+				if err := hook(ctx, pc, env); err != nil {
+					return fmt.Errorf("run: command %s: pre-run: %w", parsed.Name(), err)
+				}
+			}
+			post = append(post, cmd.PersistentPostRun())
+			if i == len(invoked)-1 {
+				post = append(post, cmd.PostRun())
+			}
+		}
+		err := pc.Invoked().Command().Handler()(ctx, pc, env)
+		var pe *command.ParseError
+		if errors.As(err, &pe) {
+			return r.usageError(pe)
+		}
+		return err
+	}()
+	errs := []error{err}
+	for i := len(post) - 1; i >= 0; i-- {
+		if post[i] == nil {
+			continue
+		}
+		if postErr := post[i](ctx, pc, env); postErr != nil {
+			errs = append(errs, fmt.Errorf("run: post-run: %w", postErr))
+		}
+	}
+	if len(errs) == 1 {
+		return err
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,78 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/SpencerBrown/go-http/command"
+)
+
+func TestRunHooks(t *testing.T) {
+	var calls []string
+	record := func(name string, fail string) command.CommandHandler {
+		return func(ctx context.Context, pc *command.ParsedCommands, env command.Env) error {
+			calls = append(calls, name)
+			if name == fail {
+				return errors.New(name + " failed")
+			}
+			return nil
+		}
+	}
+	build := func(fail string) *Runner {
+		root := command.NewCommandMust("app", nil, "app", "", nil)
+		root.SetPersistentPreRun(record("app persistent pre", fail))
+		root.SetPersistentPostRun(record("app persistent post", fail))
+		root.SetPreRun(record("app pre", fail)) // not invoked, so not run
+		mid := command.NewCommandMust("db", nil, "db", "", nil)
+		mid.SetPersistentPreRun(record("db persistent pre", fail))
+		mid.SetPersistentPostRun(record("db persistent post", fail))
+		leaf := command.NewCommandMust("migrate", nil, "migrate", "", nil)
+		leaf.SetPreRun(record("migrate pre", fail))
+		leaf.SetHandler(record("migrate", fail))
+		leaf.SetPostRun(record("migrate post", fail))
+		mid.AddSubcommandMust(leaf)
+		root.AddSubcommandMust(mid)
+		cmds := command.NewCommands()
+		cmds.AddCommandMust(root)
+		return &Runner{
+			Commands:    &cmds,
+			Args:        []string{"prog", "app", "db", "migrate"},
+			Output:      &bytes.Buffer{},
+			ErrorOutput: &bytes.Buffer{},
+			Signals:     make(chan os.Signal),
+		}
+	}
+
+	tests := []struct {
+		name      string
+		fail      string
+		wantCalls []string
+		wantErr   string
+	}{
+		{name: "success", wantCalls: []string{"app persistent pre", "db persistent pre", "migrate pre", "migrate",
+			"migrate post", "db persistent post", "app persistent post"}},
+		{name: "handler fails", fail: "migrate", wantErr: "migrate failed", wantCalls: []string{"app persistent pre", "db persistent pre", "migrate pre", "migrate",
+			"migrate post", "db persistent post", "app persistent post"}},
+		{name: "pre-run fails", fail: "db persistent pre", wantErr: "run: command db: pre-run: db persistent pre failed",
+			wantCalls: []string{"app persistent pre", "db persistent pre", "app persistent post"}},
+		{name: "post-run fails", fail: "db persistent post", wantErr: "run: post-run: db persistent post failed", wantCalls: []string{"app persistent pre", "db persistent pre", "migrate pre", "migrate",
+			"migrate post", "db persistent post", "app persistent post"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			err := build(tt.fail).Run(context.Background(), false)
+			if !slices.Equal(calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Run returned %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -6,59 +6,208 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/SpencerBrown/go-http/command"
+	"github.com/SpencerBrown/go-http/completion"
 )
 
+// Runnable is something that can be run with a context
 type Runnable interface {
 	Run(context.Context, bool) error
 }
 
+// Runner runs a command line against a tree of Commands, with injected environment and streams so it can be tested
 type Runner struct {
-	Commands    *command.Commands      // The template for the expected command line 
-	Args        []string               // The actual command line
-	GetEnvVar   func(string) string    // A function to get an environment variable
-	GetWorkDir  func() (string, error) // A function to get the working directory
-	Input       io.Reader              // The input stream
-	Output      io.Writer              // The output stream
-	ErrorOutput io.Writer              // The error output stream
+	Commands     *command.Commands           // The template for the expected command line
+	Args         []string                    // The actual command line
+	GetEnvVar    func(string) string         // A function to get an environment variable
+	LookupEnvVar func(string) (string, bool) // If not nil, used like os.LookupEnv instead of GetEnvVar, so that an empty variable counts as set
+	EnvPrefix    string                      // If not empty, options can be set by environment variables named PREFIX_OPTION
+	ConfigOption string                      // If not empty, the name of the option holding the path of a configuration file
+	Strict       bool                        // If true, unknown commands are errors rather than the start of the args
+	GetWorkDir   func() (string, error)      // A function to get the working directory
+	Input        io.Reader                   // The input stream
+	Output       io.Writer                   // The output stream
+	ErrorOutput  io.Writer                   // The error output stream
+	Signals      <-chan os.Signal            // Signals to watch while the handler runs; if nil, SIGINT, SIGTERM and SIGHUP are received from the system
+	GracePeriod  time.Duration               // How long the handler has to stop after SIGINT or SIGTERM, and the time limit for the shutdown hooks; 0 for no limit
+	Reload       func(context.Context) error // Called on SIGHUP, or nil to ignore SIGHUP
+
+	hooksMu       sync.Mutex     // guards shutdownHooks, which handlers may add to while the hooks run
+	shutdownHooks []ShutdownHook // hooks registered with OnShutdown
 }
 
 // the following copied from Mat Ryer's blog post "How I write HTTP services in Go after 13 years"
 // https://grafana.com/blog/2024/02/09/how-i-write-http-services-in-go-after-13-years/
 
+// Run parses the command line in r.Args against r.Commands and calls the handler of the last command found.
+// The handler runs between the pre-run and post-run hooks of the invoked commands, see command.Command.SetPersistentPreRun.
+// If --help or -h was given, Run writes the help for the command to r.Output instead of calling the handler.
+// If the command line does not fit the commands, or names a command without a handler, Run writes the problem
+// to r.ErrorOutput and returns a *UsageError.
+// If the first argument is completion.EntryPoint, Run writes the shell completion candidates for the rest of the arguments.
+// r.Args[0] is the program name and is skipped. If debug is true, the Runner and the parsed commands are written to r.ErrorOutput.
+// The handler is given a context that is cancelled on SIGINT or SIGTERM. A second signal, or the handler not returning
+// within r.GracePeriod, makes Run return without waiting for it. SIGHUP calls r.Reload. See OnShutdown for shutdown hooks.
+// Run returns the error returned by the handler, joined with any errors from the shutdown hooks.
+// If the handler failed with context.Canceled after a signal, or Run forced an exit, the error is an ExitError
+// with ExitInterrupt or ExitTerminate.
 func (r *Runner) Run(ctx context.Context, debug bool) error {
+	if r.Commands == nil {
+		return errors.New("run: no Commands to run")
+	}
+	if len(r.Args) > 1 && r.Args[1] == completion.EntryPoint {
+		// called back from a shell completion script, which reads the output
+		return completion.Write(r.Output, *r.Commands, r.Args[2:])
+	}
 	if debug {
-		fmt.Println(r.String())
+		fmt.Fprintln(r.ErrorOutput, r.String())
+	}
+	pc, err := r.prepare(debug)
+	if err != nil || pc == nil {
+		return err
 	}
-	// run the program
-	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
-	defer stop()
-
 	// the actual program, which watches for the ctx being signaled
-	// there's probably a fancy way of putting some error code in the context
-
-	i := 0
-	le := len(r.Args) - 1
-	for {
-		select {
-		case <-ctx.Done():
-			return errors.New("Interrupted!")
-		default:
-			time.Sleep(time.Second / 2)
-			if i++; i > le {
-				i = 1
-			}
-			if le <= 0 {
-				fmt.Fprintln(r.Output, 0)
-			} else {
-				fmt.Fprintln(r.Output, i, r.Args[i])
-			}
+	return r.runHandler(ctx, pc)
+}
+
+// Execute parses r.Args and calls the handler of the last command found, with its lifecycle hooks, like Run,
+// but without shell completion or signal handling. It is for running commands within another program,
+// such as a server, where ctx carries the cancellation. The shutdown hooks run when the handler returns.
+func (r *Runner) Execute(ctx context.Context) error {
+	if r.Commands == nil {
+		return errors.New("run: no Commands to run")
+	}
+	pc, err := r.prepare(false)
+	if err != nil || pc == nil {
+		return err
+	}
+	return r.shutdown(ctx, execute(ctx, pc, r))
+}
+
+// prepare parses r.Args and returns the parsed commands, whose invoked command has a handler.
+// If help was requested, prepare writes it and returns nil for the parsed commands.
+func (r *Runner) prepare(debug bool) (*command.ParsedCommands, error) {
+	var cmdArgs []string
+	if len(r.Args) > 1 {
+		cmdArgs = r.Args[1:]
+	}
+	parser := command.Parser{
+		GetEnvVar:    r.GetEnvVar,
+		LookupEnvVar: r.LookupEnvVar,
+		EnvPrefix:    r.EnvPrefix,
+		ConfigOption: r.ConfigOption,
+		GetWorkDir:   r.GetWorkDir,
+		Strict:       r.Strict,
+		ProgName:     r.progName(),
+	}
+	pc, err := parser.Parse(*r.Commands, cmdArgs)
+	if err != nil {
+		var pe *command.ParseError
+		if errors.As(err, &pe) {
+			return nil, r.usageError(pe)
 		}
+		return nil, err
+	}
+	if debug {
+		fmt.Fprintln(r.ErrorOutput, pc.String())
+	}
+	if pc.HelpRequested() {
+		return nil, pc.WriteHelp(r.Output, pc.ProgName())
+	}
+	invoked := pc.Invoked()
+	if invoked == nil {
+		return nil, NewExitError(ExitUsage, errors.New("run: no command given"))
+	}
+	if invoked.Command().Handler() == nil {
+		// a command that only groups its subcommands was given without one
+		path := make([]string, 0)
+		for _, parsed := range pc.Commands() {
+			path = append(path, parsed.Name())
+		}
+		err := fmt.Errorf("command %s cannot be run by itself", invoked.Name())
+		if len(invoked.Command().Subcommands()) > 0 {
+			err = fmt.Errorf("command %s needs a subcommand", invoked.Name())
+		}
+		return nil, r.usageError(&command.ParseError{Path: path, Err: err})
+	}
+	return pc, nil
+}
+
+// Main runs r and exits the program with the exit code for the error from Run, see ExitCode.
+// Any error message is written to r.ErrorOutput first, except for a UsageError, which Run has already written.
+// Main does not return.
+func Main(ctx context.Context, r *Runner, debug bool) {
+	os.Exit(r.main(ctx, debug))
+}
+
+// main runs r, writes any error message and returns the exit code
+func (r *Runner) main(ctx context.Context, debug bool) int {
+	err := r.Run(ctx, debug)
+	var usageErr *UsageError
+	var exitErr *ExitError
+	switch {
+	case err == nil, errors.As(err, &usageErr):
+	case errors.As(err, &exitErr) && exitErr.Err == nil:
+	default:
+		fmt.Fprintf(r.ErrorOutput, "%s: %s\n", r.progName(), err)
+	}
+	return ExitCode(err)
+}
+
+// usageError writes the message of a parse error to the error output, with the command to get help,
+// and returns it as a UsageError
+func (r *Runner) usageError(pe *command.ParseError) error {
+	helpCmd := append([]string{r.progName()}, pe.Path...)
+	fmt.Fprintf(r.ErrorOutput, "%s: %s\nRun '%s --help' for usage.\n", r.progName(), pe.Err, strings.Join(helpCmd, " "))
+	return &UsageError{Err: pe}
+}
+
+// progName returns the name of the program from the first argument, without any directory
+func (r *Runner) progName() string {
+	if len(r.Args) == 0 {
+		return ""
+	}
+	return filepath.Base(r.Args[0])
+}
+
+// Stdin returns the input stream, so that a Runner can be used as a command.Env
+func (r *Runner) Stdin() io.Reader {
+	return r.Input
+}
+
+// Stdout returns the output stream
+func (r *Runner) Stdout() io.Writer {
+	return r.Output
+}
+
+// Stderr returns the error output stream
+func (r *Runner) Stderr() io.Writer {
+	return r.ErrorOutput
+}
+
+// Getenv returns the value of an environment variable, or "" if there is no LookupEnvVar or GetEnvVar function
+func (r *Runner) Getenv(name string) string {
+	if r.LookupEnvVar != nil {
+		value, _ := r.LookupEnvVar(name)
+		return value
+	}
+	if r.GetEnvVar == nil {
+		return ""
+	}
+	return r.GetEnvVar(name)
+}
+
+// Getwd returns the working directory
+func (r *Runner) Getwd() (string, error) {
+	if r.GetWorkDir == nil {
+		return "", errors.New("run: no GetWorkDir function")
 	}
+	return r.GetWorkDir()
 }
 
 func (r *Runner) String() string {
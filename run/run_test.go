@@ -0,0 +1,186 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/SpencerBrown/go-http/command"
+	"github.com/SpencerBrown/go-http/option"
+)
+
+// newTestRunner creates a Runner with a small command tree whose handlers report what they were called with
+func newTestRunner(args ...string) (*Runner, *bytes.Buffer) {
+	opts := option.NewOptions()
+	opts.AddOptionMust(option.NewOptionMust("name", nil, 'n', nil, "name", "", true, "world", nil))
+	root := command.NewCommandMust("app", nil, "app", "", nil)
+	hello := command.NewCommandMust("hello", []string{"hi"}, "say hello", "", opts)
+	hello.SetHandler(func(ctx context.Context, pc *command.ParsedCommands, env command.Env) error {
+		name := option.GetParsedValueMust[string](pc.Invoked().Options().GetParsedOption("name"))
+		_, err := fmt.Fprintf(env.Stdout(), "hello %s %s\n", name, strings.Join(pc.Args(), " "))
+		return err
+	})
+	fail := command.NewCommandMust("fail", nil, "fail", "", nil)
+	fail.SetHandler(func(ctx context.Context, pc *command.ParsedCommands, env command.Env) error {
+		return errors.New("failed")
+	})
+	wait := command.NewCommandMust("wait", nil, "wait until cancelled", "", nil)
+	wait.SetHandler(func(ctx context.Context, pc *command.ParsedCommands, env command.Env) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	exit := command.NewCommandMust("exit", nil, "exit with status 3", "", nil)
+	exit.SetHandler(func(ctx context.Context, pc *command.ParsedCommands, env command.Env) error {
+		return NewExitError(3, nil)
+	})
+	root.AddSubcommandMust(hello)
+	root.AddSubcommandMust(fail)
+	root.AddSubcommandMust(wait)
+	root.AddSubcommandMust(exit)
+	root.AddSubcommandMust(command.NewHelpCommand())
+	cmds := command.NewCommands()
+	cmds.AddCommandMust(root)
+	out := &bytes.Buffer{}
+	return &Runner{
+		Commands:    &cmds,
+		Args:        append([]string{"prog"}, args...),
+		GetEnvVar:   func(string) string { return "" },
+		GetWorkDir:  func() (string, error) { return "/work", nil },
+		Input:       strings.NewReader(""),
+		Output:      out,
+		ErrorOutput: &bytes.Buffer{},
+	}, out
+}
+
+func TestRunDispatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantOut string
+		wantErr string
+	}{
+		{
+			name:    "leaf handler",
+			args:    []string{"app", "hi", "--name=you", "a", "b"},
+			wantOut: "hello you a b\n",
+		},
+		{
+			name:    "help instead of handler",
+			args:    []string{"app", "fail", "--help"},
+			wantOut: "Usage: prog app fail [options] [args...]\n\nfail\n\nOptions:\n  -h, --help      show help\n",
+		},
+		{
+			name:    "handler error",
+			args:    []string{"app", "fail"},
+			wantErr: "failed",
+		},
+		{
+			name:    "no handler",
+			args:    []string{"app"},
+			wantErr: "command app needs a subcommand",
+		},
+		{
+			name:    "no command",
+			args:    []string{},
+			wantErr: "run: no command given",
+		},
+		{
+			name:    "parse error",
+			args:    []string{"app", "hello", "--bogus"},
+			wantErr: "unknown option",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, out := newTestRunner(tt.args...)
+			err := r.Run(context.Background(), false)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run(%v) error = %v, want %q", tt.args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run(%v) returned error %v", tt.args, err)
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("Run(%v) output = %q, want %q", tt.args, out.String(), tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestRunDebug(t *testing.T) {
+	r, out := newTestRunner("app", "hello")
+	if err := r.Run(context.Background(), true); err != nil {
+		t.Fatalf("Run returned error %v", err)
+	}
+	if out.String() != "hello world \n" {
+		t.Errorf("Run output = %q, want only the handler's output", out.String())
+	}
+	if r.ErrorOutput.(*bytes.Buffer).Len() == 0 {
+		t.Errorf("Run wrote no debug output to the error output")
+	}
+}
+
+func TestRunUsageError(t *testing.T) {
+	r, out := newTestRunner("app", "hello", "--bogus")
+	err := r.Run(context.Background(), false)
+	var usageErr *UsageError
+	if !errors.As(err, &usageErr) || usageErr.ExitCode() != 2 {
+		t.Fatalf("Run returned %v, want a UsageError with exit code 2", err)
+	}
+	var unknown *command.UnknownOptionError
+	if !errors.As(err, &unknown) || unknown.Token != "--bogus" {
+		t.Errorf("Run returned %v, want an UnknownOptionError for --bogus", err)
+	}
+	want := "prog: unknown option --bogus for command hello\nRun 'prog app hello --help' for usage.\n"
+	if got := r.ErrorOutput.(*bytes.Buffer).String(); got != want {
+		t.Errorf("Run error output = %q, want %q", got, want)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Run output = %q, want nothing", out.String())
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		args      []string
+		wantCode  int
+		wantError string
+	}{
+		{name: "success", ctx: context.Background(), args: []string{"app", "hello"}, wantCode: ExitOK},
+		{name: "failure", ctx: context.Background(), args: []string{"app", "fail"}, wantCode: ExitFailure, wantError: "prog: failed\n"},
+		{name: "usage", ctx: context.Background(), args: []string{"app", "hello", "-x"}, wantCode: ExitUsage,
+			wantError: "prog: unknown option -x for command hello\nRun 'prog app hello --help' for usage.\n"},
+		{name: "no command", ctx: context.Background(), args: []string{}, wantCode: ExitUsage, wantError: "prog: run: no command given\n"},
+		{name: "no handler", ctx: context.Background(), args: []string{"app"}, wantCode: ExitUsage,
+			wantError: "prog: command app needs a subcommand\nRun 'prog app --help' for usage.\n"},
+		{name: "interrupted", ctx: cancelled, args: []string{"app", "wait"}, wantCode: ExitInterrupt, wantError: "prog: run: interrupted\n"},
+		{name: "help for unknown command", ctx: context.Background(), args: []string{"app", "help", "bogus"}, wantCode: ExitUsage,
+			wantError: "prog: unknown command bogus for command app\nRun 'prog app --help' for usage.\n"},
+		{name: "handler exit code", ctx: context.Background(), args: []string{"app", "exit"}, wantCode: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newTestRunner(tt.args...)
+			if code := r.main(tt.ctx, false); code != tt.wantCode {
+				t.Errorf("main(%v) = %d, want %d", tt.args, code, tt.wantCode)
+			}
+			if got := r.ErrorOutput.(*bytes.Buffer).String(); got != tt.wantError {
+				t.Errorf("main(%v) error output = %q, want %q", tt.args, got, tt.wantError)
+			}
+		})
+	}
+	wrapped := fmt.Errorf("context: %w", NewExitError(ExitTerminate, errors.New("terminated")))
+	if code := ExitCode(wrapped); code != ExitTerminate {
+		t.Errorf("ExitCode(%v) = %d, want %d", wrapped, code, ExitTerminate)
+	}
+}
@@ -0,0 +1,127 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/SpencerBrown/go-http/command"
+)
+
+// ShutdownHook is a function run by Runner.Run or Runner.Execute after the handler finishes, to release what the program holds.
+type ShutdownHook = command.ShutdownHook
+
+// OnShutdown registers a hook that Run or Execute calls after the handler finishes, or that Run calls after a forced exit.
+// Hooks run in the reverse order they were registered, like deferred calls, and all of them run even if some fail.
+// Each hook runs once: Run and Execute forget the hooks they have run. Handlers register hooks through their command.Env.
+// After a forced exit the hooks run while the handler may still be executing, so a hook must not depend on it having returned,
+// and a hook registered after the hooks have started does not run.
+func (r *Runner) OnShutdown(hook ShutdownHook) {
+	r.hooksMu.Lock()
+	defer r.hooksMu.Unlock()
+	r.shutdownHooks = append(r.shutdownHooks, hook)
+}
+
+// signalCode returns the exit code and message for a signal that stops the program
+func signalCode(sig os.Signal) (int, string) {
+	if sig == syscall.SIGTERM {
+		return ExitTerminate, "terminated"
+	}
+	return ExitInterrupt, "interrupted"
+}
+
+// runHandler calls the handler, with the lifecycle hooks around it, and watches for signals while it runs.
+// SIGINT or SIGTERM cancels the handler's context. A second one, or the grace period running out,
+// forces Run to return without waiting for the handler. SIGHUP calls r.Reload.
+// The shutdown hooks run before runHandler returns.
+func (r *Runner) runHandler(ctx context.Context, pc *command.ParsedCommands) error {
+	signals := r.Signals
+	if signals == nil {
+		c := make(chan os.Signal, 2)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+		defer signal.Stop(c)
+		signals = c
+	}
+	handlerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- execute(handlerCtx, pc, r)
+	}()
+
+	var err error
+	var received os.Signal
+	var grace <-chan time.Time
+wait:
+	for {
+		select {
+		case err = <-done:
+			break wait
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				if r.Reload != nil {
+					if reloadErr := r.Reload(handlerCtx); reloadErr != nil {
+						fmt.Fprintf(r.ErrorOutput, "%s: reload: %s\n", r.progName(), reloadErr)
+					}
+				}
+				continue
+			}
+			if received != nil {
+				code, msg := signalCode(sig)
+				err = NewExitError(code, fmt.Errorf("run: %s again, forced exit", msg))
+				break wait
+			}
+			received = sig
+			cancel()
+			if r.GracePeriod > 0 {
+				timer := time.NewTimer(r.GracePeriod)
+				defer timer.Stop()
+				grace = timer.C
+			}
+		case <-grace:
+			code, _ := signalCode(received)
+			err = NewExitError(code, fmt.Errorf("run: handler did not stop within %s, forced exit", r.GracePeriod))
+			break wait
+		}
+	}
+
+	var exitErr *ExitError
+	if err != nil && handlerCtx.Err() != nil && errors.Is(err, context.Canceled) && !errors.As(err, &exitErr) {
+		code, msg := ExitInterrupt, "interrupted"
+		if received != nil {
+			code, msg = signalCode(received)
+		}
+		err = NewExitError(code, errors.New("run: "+msg))
+	}
+	return r.shutdown(ctx, err)
+}
+
+// shutdown runs the shutdown hooks in reverse order and returns err joined with their errors.
+// The hooks get a context that is not cancelled by the signal, limited to the grace period if there is one.
+func (r *Runner) shutdown(ctx context.Context, err error) error {
+	ctx = context.WithoutCancel(ctx)
+	if r.GracePeriod > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.GracePeriod)
+		defer cancel()
+	}
+	r.hooksMu.Lock()
+	hooks := r.shutdownHooks
+	r.shutdownHooks = nil
+	r.hooksMu.Unlock()
+	errs := []error{err}
+	for _, hook := range slices.Backward(hooks) {
+		if hookErr := hook(ctx); hookErr != nil {
+			errs = append(errs, fmt.Errorf("run: shutdown: %w", hookErr))
+		}
+	}
+	if len(errs) == 1 {
+		return err
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,166 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/SpencerBrown/go-http/command"
+)
+
+func TestRunSignals(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		signals  []os.Signal
+		grace    time.Duration
+		wantCode int
+		wantErr  string
+	}{
+		{name: "interrupt", args: []string{"app", "wait"}, signals: []os.Signal{os.Interrupt}, wantCode: ExitInterrupt, wantErr: "run: interrupted"},
+		{name: "terminate", args: []string{"app", "wait"}, signals: []os.Signal{syscall.SIGTERM}, wantCode: ExitTerminate, wantErr: "run: terminated"},
+		{name: "grace period", args: []string{"app", "stuck"}, signals: []os.Signal{syscall.SIGTERM}, grace: 10 * time.Millisecond,
+			wantCode: ExitTerminate, wantErr: "did not stop within 10ms"},
+		{name: "second signal", args: []string{"app", "stuck"}, signals: []os.Signal{syscall.SIGTERM, os.Interrupt},
+			wantCode: ExitInterrupt, wantErr: "run: interrupted again"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newTestRunner(tt.args...)
+			stuck := make(chan struct{})
+			defer close(stuck)
+			addStuckCommand(r, stuck)
+			signals := make(chan os.Signal, len(tt.signals))
+			for _, sig := range tt.signals {
+				signals <- sig
+			}
+			r.Signals = signals
+			r.GracePeriod = tt.grace
+			err := r.Run(context.Background(), false)
+			if code := ExitCode(err); code != tt.wantCode || err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Run(%v) = %v with code %d, want %q with code %d", tt.args, err, code, tt.wantErr, tt.wantCode)
+			}
+		})
+	}
+}
+
+// addStuckCommand adds a command "stuck" whose handler ignores its context and returns only when stuck is closed
+func addStuckCommand(r *Runner, stuck chan struct{}) {
+	cmd := command.NewCommandMust("stuck", nil, "ignore signals", "", nil)
+	cmd.SetHandler(func(ctx context.Context, pc *command.ParsedCommands, env command.Env) error {
+		<-stuck
+		return nil
+	})
+	(*r.Commands)[0].AddSubcommandMust(cmd)
+}
+
+func TestRunReloadAndShutdownHooks(t *testing.T) {
+	r, _ := newTestRunner("app", "wait")
+	signals := make(chan os.Signal)
+	r.Signals = signals
+	reloaded := make(chan struct{})
+	r.Reload = func(ctx context.Context) error {
+		close(reloaded)
+		return errors.New("bad config")
+	}
+	var order []int
+	for i := 1; i <= 3; i++ {
+		r.OnShutdown(func(ctx context.Context) error {
+			if ctx.Err() != nil {
+				t.Errorf("shutdown hook %d got a cancelled context", i)
+			}
+			order = append(order, i)
+			if i == 2 {
+				return errors.New("flush failed")
+			}
+			return nil
+		})
+	}
+	go func() {
+		signals <- syscall.SIGHUP
+		<-reloaded
+		signals <- os.Interrupt
+	}()
+	err := r.Run(context.Background(), false)
+	if !slices.Equal(order, []int{3, 2, 1}) {
+		t.Errorf("shutdown hooks ran in order %v, want [3 2 1]", order)
+	}
+	if ExitCode(err) != ExitInterrupt || !strings.Contains(err.Error(), "run: shutdown: flush failed") {
+		t.Errorf("Run returned %v, want an interrupt joined with the failed hook", err)
+	}
+	if got := r.ErrorOutput.(*bytes.Buffer).String(); got != "prog: reload: bad config\n" {
+		t.Errorf("Run error output = %q, want the reload error", got)
+	}
+}
+
+func TestRunShutdownHookFromStuckHandler(t *testing.T) {
+	r, _ := newTestRunner("app", "late")
+	looping := make(chan struct{})
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	late := command.NewCommandMust("late", nil, "register hooks after cancellation", "", nil)
+	late.SetHandler(func(ctx context.Context, pc *command.ParsedCommands, env command.Env) error {
+		defer close(stopped)
+		<-ctx.Done()
+		// ignore the cancellation and keep registering hooks while Run shuts down
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return nil
+			default:
+				env.OnShutdown(func(ctx context.Context) error { return nil })
+			}
+			if i == 0 {
+				close(looping)
+			}
+		}
+	})
+	(*r.Commands)[0].AddSubcommandMust(late)
+	ran := false
+	r.OnShutdown(func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+	signals := make(chan os.Signal, 2)
+	signals <- os.Interrupt
+	go func() {
+		<-looping
+		signals <- os.Interrupt
+	}()
+	r.Signals = signals
+	err := r.Run(context.Background(), false)
+	close(stop)
+	<-stopped
+	if ExitCode(err) != ExitInterrupt || !ran {
+		t.Errorf("Run returned %v and ran the first hook %t, want a forced interrupt that runs it", err, ran)
+	}
+}
+
+func TestExecuteShutdownHooks(t *testing.T) {
+	r, _ := newTestRunner("app", "cleanup")
+	runs := 0
+	cleanup := command.NewCommandMust("cleanup", nil, "register a shutdown hook", "", nil)
+	cleanup.SetHandler(func(ctx context.Context, pc *command.ParsedCommands, env command.Env) error {
+		env.OnShutdown(func(ctx context.Context) error {
+			runs++
+			return errors.New("close failed")
+		})
+		return nil
+	})
+	(*r.Commands)[0].AddSubcommandMust(cleanup)
+	for i := 1; i <= 2; i++ {
+		err := r.Execute(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "run: shutdown: close failed") {
+			t.Errorf("Execute returned %v, want the failed hook", err)
+		}
+		if runs != i {
+			t.Errorf("after %d calls of Execute the hook ran %d times, want once per call", i, runs)
+		}
+	}
+}
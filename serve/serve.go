@@ -0,0 +1,81 @@
+// Package serve provides a "serve" command that runs any http.Handler as a web server,
+// so an app built on the command tree can also act as a web server.
+// The server stops gracefully when the context from run.Runner is cancelled, on SIGINT or SIGTERM.
+package serve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/SpencerBrown/go-http/command"
+	"github.com/SpencerBrown/go-http/option"
+)
+
+// Defaults for the options of the serve command
+const (
+	DefaultAddr            = ":8080"
+	DefaultReadTimeout     = 10 * time.Second
+	DefaultWriteTimeout    = 10 * time.Second
+	DefaultShutdownTimeout = 15 * time.Second
+)
+
+// NewCommand creates a "serve" command that serves h with an http.Server.
+// The command has the options --addr, --read-timeout, --write-timeout and --shutdown-timeout.
+func NewCommand(h http.Handler) *command.Command {
+	opts := option.NewOptions()
+	opts.AddOptionMust(option.NewOptionMust("addr", nil, 0, nil, "address to listen on", "The TCP address to listen on, as host:port.", true, DefaultAddr, nil))
+	opts.AddOptionMust(option.NewOptionMust("read-timeout", nil, 0, nil, "maximum time to read a request", "", true, DefaultReadTimeout, nil))
+	opts.AddOptionMust(option.NewOptionMust("write-timeout", nil, 0, nil, "maximum time to write a response", "", true, DefaultWriteTimeout, nil))
+	opts.AddOptionMust(option.NewOptionMust("shutdown-timeout", nil, 0, nil, "maximum time to finish requests when stopping", "", true, DefaultShutdownTimeout, nil))
+	cmd := command.NewCommandMust("serve", nil, "run the web server",
+		"Serve listens on the address and serves requests until interrupted, then waits for requests in progress to finish.", opts)
+	cmd.SetHandler(func(ctx context.Context, pc *command.ParsedCommands, env command.Env) error {
+		options := pc.Invoked().Options()
+		srv := &http.Server{
+			Addr:         option.GetParsedValueMust[string](options.GetParsedOption("addr")),
+			Handler:      h,
+			ReadTimeout:  option.GetParsedValueMust[time.Duration](options.GetParsedOption("read-timeout")),
+			WriteTimeout: option.GetParsedValueMust[time.Duration](options.GetParsedOption("write-timeout")),
+		}
+		ln, err := net.Listen("tcp", srv.Addr)
+		if err != nil {
+			return fmt.Errorf("serve: %w", err)
+		}
+		fmt.Fprintf(env.Stderr(), "listening on %s\n", ln.Addr())
+		return Run(ctx, srv, ln, option.GetParsedValueMust[time.Duration](options.GetParsedOption("shutdown-timeout")))
+	})
+	return cmd
+}
+
+// Run serves srv on ln until ctx is cancelled, then shuts the server down gracefully,
+// giving requests in progress up to shutdownTimeout to finish before closing their connections.
+// Requests get contexts with the values of ctx, but they are not cancelled with it.
+// Run returns nil after a graceful shutdown, or the error that stopped the server.
+func Run(ctx context.Context, srv *http.Server, ln net.Listener, shutdownTimeout time.Duration) error {
+	srv.BaseContext = func(net.Listener) context.Context {
+		return context.WithoutCancel(ctx)
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("serve: %w", err)
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("serve: shutdown: %w", err)
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package serve
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpencerBrown/go-http/command"
+	"github.com/SpencerBrown/go-http/run"
+)
+
+// slowHandler answers /fast at once, and /slow when release is closed, after telling started
+func slowHandler(started chan<- struct{}, release <-chan struct{}) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "fast")
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		io.WriteString(w, "slow")
+	})
+	return mux
+}
+
+// get fetches a URL and returns the body, or the error as the body
+func get(url string) string {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err.Error()
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err.Error()
+	}
+	return string(body)
+}
+
+func TestRunGracefulShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := &http.Server{Handler: slowHandler(started, release)}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, srv, ln, time.Minute)
+	}()
+	url := "http://" + ln.Addr().String()
+	if got := get(url + "/fast"); got != "fast" {
+		t.Fatalf("GET /fast = %q, want fast", got)
+	}
+	slow := make(chan string, 1)
+	go func() {
+		slow <- get(url + "/slow")
+	}()
+	<-started
+	cancel()
+	// the request in progress finishes after the server starts shutting down
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if got := <-slow; got != "slow" {
+		t.Errorf("GET /slow during shutdown = %q, want slow", got)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Run returned %v after a graceful shutdown", err)
+	}
+}
+
+func TestRunShutdownTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	srv := &http.Server{Handler: slowHandler(started, release)}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, srv, ln, 10*time.Millisecond)
+	}()
+	go get("http://" + ln.Addr().String() + "/slow")
+	<-started
+	cancel()
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "serve: shutdown") {
+		t.Errorf("Run returned %v, want a shutdown timeout", err)
+	}
+}
+
+func TestNewCommand(t *testing.T) {
+	cmds := command.NewCommands()
+	cmds.AddCommandMust(NewCommand(http.NotFoundHandler()))
+	errOut := &bytes.Buffer{}
+	r := &run.Runner{
+		Commands:    &cmds,
+		Args:        []string{"prog", "serve", "--addr=127.0.0.1:0", "--shutdown-timeout=1s"},
+		Output:      &bytes.Buffer{},
+		ErrorOutput: errOut,
+		Signals:     make(chan os.Signal),
+	}
+	// a cancelled context stops the server as soon as it starts
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.Run(ctx, false); err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+	if !strings.HasPrefix(errOut.String(), "listening on 127.0.0.1:") {
+		t.Errorf("Run error output = %q, want the listening address", errOut.String())
+	}
+
+	r.Args = []string{"prog", "serve", "--addr=256.0.0.1:http"}
+	if err := r.Run(context.Background(), false); err == nil || !strings.HasPrefix(err.Error(), "serve: ") {
+		t.Errorf("Run with a bad address returned %v, want a listen error", err)
+	}
+}
@@ -49,3 +49,23 @@ func TestIndent(t *testing.T) {
 		t.Errorf("Indent(%q, %d) = %q, expected %q", input5, 4, output5, expected5)
 	}
 }
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"serve", "", 5},
+		{"serve", "serve", 0},
+		{"sevre", "serve", 2},
+		{"serv", "serve", 1},
+		{"kitten", "sitting", 3},
+		{"héllo", "hello", 1},
+	}
+	for _, tt := range tests {
+		if got := EditDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("EditDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}